@@ -1,42 +1,324 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"io/fs"
 	"log"
+	"mime"
+	"net"
 	"net/url"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/utils"
 	"github.com/samber/lo"
 
+	web "github.com/rprtr258/flatnotes/flatnotes"
 	"github.com/rprtr258/flatnotes/internal"
+	"github.com/rprtr258/flatnotes/internal/fts"
 )
 
-var (
-	responseTitleExists = func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusConflict).JSON(map[string]string{
-			"message": "Note with specified title already exists.",
-		})
+// _tokenCookie is name of cookie with access token set by frontend.
+const _tokenCookie = "token"
+
+// _listLimit is default number of items on a page of listing.
+const _listLimit = 100
+
+const (
+	// _eventsKeepAlive is interval of comments sent to event stream, so idle
+	// connections are not closed by proxies and gone clients are noticed.
+	_eventsKeepAlive = 30 * time.Second
+	// _eventsRetry is time client waits before reconnecting to event stream.
+	_eventsRetry = 3 * time.Second
+)
+
+// writeEvent writes event in server-sent events format.
+func writeEvent(w io.Writer, event internal.Event) {
+	data, _ := json.Marshal(event)
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
+
+// _immutableAsset matches names of frontend files with content hash, which
+// never change, so they might be cached forever.
+var _immutableAsset = regexp.MustCompile(`\.[0-9a-f]{8}\.[0-9a-z]+$`)
+
+// asset is frontend file with its precompressed variants, nil if there are
+// none.
+type asset struct {
+	content, gzip, brotli []byte
+	etag                  string
+}
+
+// assetETag returns weak ETag of frontend file, as compressed variants have
+// the same tag.
+func assetETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// readAsset reads frontend file along with its precompressed variants.
+func readAsset(fsys fs.FS, name string) (asset, error) {
+	if stat, err := fs.Stat(fsys, name); err != nil {
+		return asset{}, err
+	} else if stat.IsDir() {
+		return asset{}, fs.ErrNotExist
 	}
-	responseTitleInvalid = func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusBadRequest).JSON(map[string]string{
-			"message": "Title contains invalid characters.",
-		})
+
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return asset{}, err
+	}
+
+	res := asset{
+		content: content,
+		etag:    assetETag(content),
+	}
+	for ext, variant := range map[string]*[]byte{
+		".gz": &res.gzip,
+		".br": &res.brotli,
+	} {
+		if *variant, err = fs.ReadFile(fsys, name+ext); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return asset{}, err
+		}
+	}
+	return res, nil
+}
+
+// assets serves built frontend. Embedded files are read once on startup,
+// files on disk are read on each request, so frontend might be rebuilt
+// meanwhile.
+type assets struct {
+	fsys fs.FS
+	// URL path prefix frontend is served under
+	pathPrefix string
+	// files by name, nil if they are not embedded
+	cache map[string]asset
+}
+
+func newAssets(fsys fs.FS, embedded bool, pathPrefix string) (*assets, error) {
+	res := &assets{
+		fsys:       fsys,
+		pathPrefix: pathPrefix,
+	}
+	if !embedded {
+		return res, nil
+	}
+
+	res.cache = map[string]asset{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || path.Ext(name) == ".gz" || path.Ext(name) == ".br" {
+			return nil
+		}
+
+		asset, err := res.read(name)
+		if err != nil {
+			return fmt.Errorf("read %q: %w", name, err)
+		}
+
+		res.cache[name] = asset
+		return nil
+	})
+	return res, err
+}
+
+// read reads frontend file. Frontend URLs are relative, so index.html gets
+// base of them, with path prefix.
+func (a *assets) read(name string) (asset, error) {
+	res, err := readAsset(a.fsys, name)
+	if err != nil || name != "index.html" {
+		return res, err
 	}
-	responseNoteNotFound = func(c *fiber.Ctx) error {
-		return c.Status(fiber.StatusNotFound).JSON(map[string]string{
-			"message": "The note cannot be found.",
+
+	base := `<base href="` + html.EscapeString(a.pathPrefix+"/") + `">`
+	res.content = bytes.Replace(res.content, []byte("<head>"), []byte("<head>"+base), 1)
+	res.etag = assetETag(res.content)
+	// precompressed variants are without base
+	res.gzip, res.brotli = nil, nil
+	return res, nil
+}
+
+func (a *assets) get(name string) (asset, error) {
+	if a.cache == nil {
+		return a.read(name)
+	}
+
+	res, ok := a.cache[name]
+	if !ok {
+		return asset{}, fs.ErrNotExist
+	}
+	return res, nil
+}
+
+// serve sends frontend file, compressed if client accepts it.
+func (a *assets) serve(c *fiber.Ctx, name string) error {
+	asset, err := a.get(name)
+	if err != nil {
+		return fmt.Errorf("read %q: %w", name, err)
+	}
+
+	if a.cache != nil && _immutableAsset.MatchString(name) {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	} else {
+		// revalidated with etag on each use
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+	}
+	c.Set(fiber.HeaderETag, asset.etag)
+	c.Vary(fiber.HeaderAcceptEncoding)
+	if c.Fresh() {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+
+	if ext := path.Ext(name); ext == ".html" {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	} else {
+		c.Type(ext)
+	}
+
+	body := asset.content
+	switch {
+	case asset.brotli != nil && c.Request().Header.HasAcceptEncoding("br"):
+		c.Set(fiber.HeaderContentEncoding, "br")
+		body = asset.brotli
+	case asset.gzip != nil && c.Request().Header.HasAcceptEncoding("gzip"):
+		c.Set(fiber.HeaderContentEncoding, "gzip")
+		body = asset.gzip
+	}
+	return c.Send(body)
+}
+
+// errorResponse is response to error returned by handler.
+type errorResponse struct {
+	status int
+	// machine readable error code
+	code    string
+	message string
+}
+
+// _errorResponses are responses to errors of internal package, errors are
+// matched with errors.Is, so they might be wrapped.
+var _errorResponses = []struct {
+	err error
+	errorResponse
+}{
+	{internal.ErrTitleExists, errorResponse{fiber.StatusConflict, "title_exists", "Note with specified title already exists."}},
+	{internal.ErrTitleInvalid, errorResponse{fiber.StatusBadRequest, "title_invalid", "Title contains invalid characters."}},
+	{internal.ErrNotFound, errorResponse{fiber.StatusNotFound, "note_not_found", "The note cannot be found."}},
+	{internal.ErrVersionNotFound, errorResponse{fiber.StatusNotFound, "version_not_found", "The note version cannot be found."}},
+	{internal.ErrAttachmentTooLarge, errorResponse{fiber.StatusRequestEntityTooLarge, "attachment_too_large", "The attachment is too large."}},
+	{internal.ErrAttachmentNotAllowed, errorResponse{fiber.StatusUnsupportedMediaType, "attachment_not_allowed", "The attachment file type is not allowed."}},
+	{internal.ErrAttachmentNotFound, errorResponse{fiber.StatusNotFound, "attachment_not_found", "The attachment cannot be found."}},
+	{internal.ErrCursorInvalid, errorResponse{fiber.StatusBadRequest, "cursor_invalid", "The specified cursor is invalid."}},
+}
+
+// statusCode returns error code for HTTP status, e.g. "not_found" for 404.
+func statusCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(utils.StatusMessage(status), " ", "_"))
+}
+
+// errorHandler returns handler responding to errors returned by handlers with
+// JSON body having message and code of error. Unknown errors are logged and
+// reported as internal server errors.
+func errorHandler(pathPrefix string) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		return handleError(c, err, pathPrefix)
+	}
+}
+
+func handleError(c *fiber.Ctx, err error, pathPrefix string) error {
+	var (
+		conflictErr *internal.VersionConflictError
+		parseErr    *fts.ParseError
+		fiberErr    *fiber.Error
+	)
+	switch {
+	case errors.As(err, &conflictErr):
+		c.Set(fiber.HeaderETag, conflictErr.Current.ETag)
+		return c.Status(fiber.StatusPreconditionFailed).JSON(internal.ErrorResponseModel{
+			Message: "The note has been changed since it was loaded.",
+			Code:    "version_mismatch",
+			Current: &conflictErr.Current,
+		})
+	case errors.As(err, &parseErr):
+		return c.Status(fiber.StatusBadRequest).JSON(internal.ErrorResponseModel{
+			Message:  parseErr.Msg,
+			Code:     "query_invalid",
+			Position: &parseErr.Pos,
+		})
+	case errors.As(err, &fiberErr):
+		// unknown pages are handled by frontend
+		if fiberErr.Code == fiber.StatusNotFound && !strings.HasPrefix(c.Path(), pathPrefix+"/api/") {
+			return c.Redirect(pathPrefix + "/")
+		}
+
+		return c.Status(fiberErr.Code).JSON(internal.ErrorResponseModel{
+			Message: fiberErr.Message,
+			Code:    statusCode(fiberErr.Code),
 		})
 	}
-)
 
-func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
+	for _, response := range _errorResponses {
+		if errors.Is(err, response.err) {
+			return c.Status(response.status).JSON(internal.ErrorResponseModel{
+				Message: response.message,
+				Code:    response.code,
+			})
+		}
+	}
+
+	log.Printf("%s %s: %s\n", c.Method(), c.Path(), err.Error())
+	return c.Status(fiber.StatusInternalServerError).JSON(internal.ErrorResponseModel{
+		Message: "Internal server error.",
+		Code:    statusCode(fiber.StatusInternalServerError),
+	})
+}
+
+// staticHeaders forbids browsers to run attachments: they must not guess
+// type of files, and files other than images and PDFs are downloaded rather
+// than opened, sandboxed if opened anyway.
+func staticHeaders(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+	if err := c.Next(); err != nil {
+		return err
+	}
+
+	contentType := string(c.Response().Header.ContentType())
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		contentType = mediaType
+	}
+
+	// svg images might contain scripts
+	if strings.HasPrefix(contentType, "image/") && contentType != "image/svg+xml" || contentType == "application/pdf" {
+		return nil
+	}
+
+	c.Set(fiber.HeaderContentDisposition, "attachment")
+	c.Set(fiber.HeaderContentSecurityPolicy, "sandbox")
+	return nil
+}
+
+func setupApp(app fiber.Router, config internal.Config, flatnotes *internal.App, frontend *assets) {
 	// totp = (
 	//     pyotp.TOTP(config.totp_key) if config.auth_type == AuthType.TOTP else None
 	// )
@@ -55,7 +337,18 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 	authenticate := func(c *fiber.Ctx) error {
 		return c.Next()
 	}
+	authenticateCookie := authenticate
 	if config.AuthType != internal.AuthTypeNone && config.AuthType != internal.AuthTypeReadOnly {
+		authenticateToken := func(c *fiber.Ctx, token string) error {
+			username, err := internal.ValidateToken(config, token)
+			if err != nil {
+				return fiber.NewError(fiber.StatusUnauthorized, fmt.Errorf("validate token: %w", err).Error())
+			}
+
+			c.SetUserContext(internal.WithAuthor(c.UserContext(), username))
+			return c.Next()
+		}
+
 		authenticate = func(c *fiber.Ctx) error {
 			authorizationHeaders := c.GetReqHeaders()[fiber.HeaderAuthorization]
 			if len(authorizationHeaders) != 1 {
@@ -67,22 +360,23 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 				return fiber.NewError(fiber.StatusUnauthorized, "invalid token in Authorization header")
 			}
 
-			if err := internal.ValidateToken(config, token); err != nil {
-				return fiber.NewError(fiber.StatusUnauthorized, fmt.Errorf("validate token: %w", err).Error())
+			return authenticateToken(c, token)
+		}
+
+		// Attachments and event stream are requested by browser itself, e.g.
+		// for images in notes, so token is also accepted from cookie set by
+		// frontend.
+		authenticateCookie = func(c *fiber.Ctx) error {
+			if token := c.Cookies(_tokenCookie); token != "" && c.Get(fiber.HeaderAuthorization) == "" {
+				return authenticateToken(c, token)
 			}
 
-			return c.Next()
+			return authenticate(c)
 		}
 	}
 
 	root := func(c *fiber.Ctx) error {
-		html, err := os.ReadFile("flatnotes/dist/index.html")
-		if err != nil {
-			return fmt.Errorf("read index.html: %w", err)
-		}
-
-		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
-		return c.Send(html)
+		return frontend.serve(c, "index.html")
 	}
 	app.Get("/", root)
 	app.Get("/login", root)
@@ -101,19 +395,139 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 
 		res, err := flatnotes.GetNote(title, includeContent)
 		if err != nil {
-			switch err {
-			case internal.ErrTitleInvalid:
-				return responseTitleInvalid(c)
-			case internal.ErrNotFound:
-				return responseNoteNotFound(c)
-			default:
-				return err
+			return err
+		}
+
+		c.Set(fiber.HeaderETag, res.ETag)
+		return c.JSON(res)
+	})
+
+	// Get a list of notes linking to a note.
+	app.Get("/api/notes/:title/backlinks", authenticate, func(c *fiber.Ctx) error {
+		title, err := url.QueryUnescape(c.Params("title"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid title: %w", err).Error())
+		}
+
+		res, err := flatnotes.GetBacklinks(title, c.QueryInt("limit", _listLimit), c.Query("cursor"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(res)
+	})
+
+	// Get a list of notes a note links to.
+	app.Get("/api/notes/:title/links", authenticate, func(c *fiber.Ctx) error {
+		title, err := url.QueryUnescape(c.Params("title"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid title: %w", err).Error())
+		}
+
+		res, err := flatnotes.GetLinks(title)
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(res)
+	})
+
+	// Stream changes of notes as server-sent events. Client resumes from last
+	// event it got by sending its id in Last-Event-ID header.
+	app.Get("/api/events", authenticateCookie, func(c *fiber.Ctx) error {
+		missed, events, unsubscribe := flatnotes.Events.Subscribe(c.Get("Last-Event-ID"))
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		// disable buffering in reverse proxies
+		c.Set("X-Accel-Buffering", "no")
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer unsubscribe()
+
+			keepAlive := time.NewTicker(_eventsKeepAlive)
+			defer keepAlive.Stop()
+
+			fmt.Fprintf(w, "retry: %d\n\n", _eventsRetry.Milliseconds())
+			for _, event := range missed {
+				writeEvent(w, event)
+			}
+
+			// write fails once client is gone
+			for w.Flush() == nil {
+				select {
+				case event, ok := <-events:
+					if !ok {
+						return
+					}
+
+					writeEvent(w, event)
+				case <-keepAlive.C:
+					w.WriteString(": keep-alive\n\n")
+				}
 			}
+		})
+		return nil
+	})
+
+	// Get a list of links to notes which do not exist.
+	app.Get("/api/links/unresolved", authenticate, func(c *fiber.Ctx) error {
+		res, err := flatnotes.GetUnresolvedLinks(c.QueryInt("limit", _listLimit), c.Query("cursor"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(res)
+	})
+
+	// Get a list of previous versions of a note.
+	app.Get("/api/notes/:title/history", authenticate, func(c *fiber.Ctx) error {
+		title, err := url.QueryUnescape(c.Params("title"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid title: %w", err).Error())
+		}
+
+		res, err := flatnotes.GetHistory(title, c.QueryInt("limit", _listLimit), c.Query("cursor"))
+		if err != nil {
+			return err
 		}
 
 		return c.JSON(res)
 	})
 
+	// Get a previous version of a note.
+	app.Get("/api/notes/:title/history/:id", authenticate, func(c *fiber.Ctx) error {
+		title, err := url.QueryUnescape(c.Params("title"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid title: %w", err).Error())
+		}
+
+		res, err := flatnotes.GetVersion(title, c.Params("id"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(res)
+	})
+
+	// Get a unified diff between two versions of a note, current version is
+	// used if from or to is not specified.
+	app.Get("/api/notes/:title/diff", authenticate, func(c *fiber.Ctx) error {
+		title, err := url.QueryUnescape(c.Params("title"))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid title: %w", err).Error())
+		}
+
+		res, err := flatnotes.DiffVersions(title, c.Query("from"), c.Query("to"))
+		if err != nil {
+			return err
+		}
+
+		c.Set(fiber.HeaderContentType, "text/x-diff; charset=utf-8")
+		return c.SendString(res)
+	})
+
 	if config.AuthType != internal.AuthTypeReadOnly {
 		if config.AuthType != internal.AuthTypeNone {
 			app.Post("/api/token",
@@ -140,18 +554,12 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 			}
 			data.Title = strings.TrimSpace(data.Title)
 
-			res, err := flatnotes.CreateNote(data)
+			res, err := flatnotes.CreateNote(c.UserContext(), data)
 			if err != nil {
-				switch err {
-				case internal.ErrTitleInvalid:
-					return responseTitleInvalid(c)
-				case internal.ErrTitleExists:
-					return responseTitleExists(c)
-				default:
-					return err
-				}
+				return err
 			}
 
+			c.Set(fiber.HeaderETag, res.ETag)
 			return c.JSON(res)
 		})
 
@@ -167,17 +575,12 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 				return fiber.NewError(fiber.StatusBadRequest, err.Error())
 			}
 
-			res, err := flatnotes.UpdateNote(title, new_data)
+			res, err := flatnotes.UpdateNote(c.UserContext(), title, new_data, c.Get(fiber.HeaderIfMatch))
 			if err != nil {
-				// except InvalidTitleError:
-				//     return invalid_title_response
-				// except FileExistsError:
-				//     return title_exists_response
-				// except FileNotFoundError:
-				//     return note_not_found_response
 				return err
 			}
 
+			c.Set(fiber.HeaderETag, res.ETag)
 			return c.JSON(res)
 		})
 
@@ -187,23 +590,113 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 				return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid title: %w", err).Error())
 			}
 
-			if err := flatnotes.DeleteNote(title); err != nil {
-				// except InvalidTitleError:
-				//     return invalid_title_response
-				// except FileNotFoundError:
-				//     return note_not_found_response
+			return flatnotes.DeleteNote(c.UserContext(), title, c.Get(fiber.HeaderIfMatch))
+		})
+
+		// Upload an attachment.
+		app.Post("/api/attachments", authenticate, func(c *fiber.Ctx) error {
+			file, err := c.FormFile("file")
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("get file: %w", err).Error())
+			}
+
+			if file.Size > config.AttachmentMaxSize {
+				return internal.ErrAttachmentTooLarge
+			}
+
+			f, err := file.Open()
+			if err != nil {
+				return fmt.Errorf("open uploaded file: %w", err)
+			}
+			defer f.Close()
+
+			res, err := flatnotes.CreateAttachment(file.Filename, f)
+			if err != nil {
 				return err
 			}
 
-			return nil
+			return c.Status(fiber.StatusCreated).JSON(res)
+		})
+
+		app.Delete("/api/attachments/:filename", authenticate, func(c *fiber.Ctx) error {
+			filename, err := url.PathUnescape(c.Params("filename"))
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid filename: %w", err).Error())
+			}
+
+			return flatnotes.DeleteAttachment(filename)
+		})
+
+		// Get a list of deleted notes.
+		app.Get("/api/trash", authenticate, func(c *fiber.Ctx) error {
+			res, err := flatnotes.GetTrash(c.QueryInt("limit", _listLimit), c.Query("cursor"))
+			if err != nil {
+				return err
+			}
+
+			return c.JSON(res)
+		})
+
+		// Restore a deleted note, optionally under a new title.
+		app.Post("/api/trash/:id/restore", authenticate, func(c *fiber.Ctx) error {
+			var data internal.TrashRestoreModel
+			if len(c.Body()) > 0 {
+				if err := c.BodyParser(&data); err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, err.Error())
+				}
+			}
+
+			res, err := flatnotes.RestoreTrashedNote(c.UserContext(), c.Params("id"), strings.TrimSpace(lo.FromPtr(data.NewTitle)))
+			if err != nil {
+				return err
+			}
+
+			c.Set(fiber.HeaderETag, res.ETag)
+			return c.JSON(res)
+		})
+
+		// Permanently delete a note from trash.
+		app.Delete("/api/trash/:id", authenticate, func(c *fiber.Ctx) error {
+			return flatnotes.PurgeTrashedNote(c.Params("id"))
+		})
+
+		// Permanently delete all notes from trash.
+		app.Delete("/api/trash", authenticate, func(c *fiber.Ctx) error {
+			return flatnotes.PurgeTrash(time.Now())
+		})
+
+		// Restore a previous version of a note, recreating it if deleted.
+		app.Post("/api/notes/:title/history/:id/restore", authenticate, func(c *fiber.Ctx) error {
+			title, err := url.QueryUnescape(c.Params("title"))
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, fmt.Errorf("invalid title: %w", err).Error())
+			}
+
+			res, err := flatnotes.RestoreVersion(c.UserContext(), title, c.Params("id"), c.Get(fiber.HeaderIfMatch))
+			if err != nil {
+				return err
+			}
+
+			c.Set(fiber.HeaderETag, res.ETag)
+			return c.JSON(res)
 		})
 	}
 
+	// Get a list of all attachments.
+	app.Get("/api/attachments", authenticate, func(c *fiber.Ctx) error {
+		res, err := flatnotes.GetAttachments(c.QueryInt("limit", _listLimit), c.Query("cursor"))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(res)
+	})
+
 	// Get a list of all indexed tags.
 	app.Get("/api/tags", authenticate, func(c *fiber.Ctx) error {
 		tags, err := flatnotes.GetTags()
 		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, fmt.Errorf("get tags: %w", err).Error())
+			return fmt.Errorf("get tags: %w", err)
 		}
 
 		return c.JSON([]string(lo.Keys(tags)))
@@ -224,20 +717,45 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 			Case("asc", internal.OrderAsc).
 			Default(internal.OrderDesc)
 		limit := c.QueryInt("limit", 0)
+		cursor := c.Query("cursor")
+		folder := c.Query("folder")
+
+		// during initial indexing results are partial, unless asked to wait
+		if c.QueryBool("wait") {
+			if err := flatnotes.WaitIndexed(c.UserContext()); err != nil {
+				return fmt.Errorf("wait for indexing: %w", err)
+			}
+		}
 
-		res, err := flatnotes.Search(term, sort, order, limit)
+		res, err := flatnotes.Search(term, sort, order, limit, cursor, folder)
 		if err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, fmt.Errorf("search: %w", err).Error())
+			return fmt.Errorf("search: %w", err)
 		}
 
 		return c.JSON(res)
 	})
 
+	// Get last attempts to deliver note changes to webhooks.
+	app.Get("/api/webhooks/deliveries", authenticate, func(c *fiber.Ctx) error {
+		res, err := flatnotes.GetWebhookDeliveries(c.QueryInt("limit", 100))
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(res)
+	})
+
+	// Get progress of initial indexing.
+	app.Get("/api/status", authenticate, func(c *fiber.Ctx) error {
+		return c.JSON(flatnotes.GetStatus())
+	})
+
 	// TODO: move config to debug
 	// TODO: hardcode auth type in frontend
 	app.Get("/api/config", func(c *fiber.Ctx) error {
 		return c.JSON(internal.ConfigModel{
-			AuthType: config.AuthType,
+			AuthType:   config.AuthType,
+			PathPrefix: config.PathPrefix,
 		})
 	})
 
@@ -247,25 +765,29 @@ func setupApp(app *fiber.App, config internal.Config, flatnotes internal.App) {
 		})
 	}
 
-	app.Static("/", "./flatnotes/dist")
+	app.Use("/static", authenticateCookie, staticHeaders)
 	app.Static("/static", filepath.Join(config.DataPath, "static"))
+	app.Get("/*", func(c *fiber.Ctx) error {
+		name := strings.TrimPrefix(path.Clean("/"+c.Params("*")), "/")
+		if !fs.ValidPath(name) {
+			return fiber.ErrNotFound
+		}
+
+		if err := frontend.serve(c, name); errors.Is(err, fs.ErrNotExist) {
+			return fiber.ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
 func run(ctx context.Context) error {
 	config := internal.NewConfig()
 	app := fiber.New(fiber.Config{
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			switch e := err.(type) {
-			case *fiber.Error:
-				if e.Code == fiber.StatusNotFound {
-					return c.Redirect("/")
-				}
-
-				return err
-			default:
-				return err
-			}
-		},
+		// leave room for multipart encoding of attachments
+		BodyLimit:    max(fiber.DefaultBodyLimit, int(config.AttachmentMaxSize)+1<<20),
+		ErrorHandler: errorHandler(config.PathPrefix),
 	})
 	app.Use(logger.New())
 	// app.Use(swagger.New(swagger.Config{
@@ -275,22 +797,123 @@ func run(ctx context.Context) error {
 	// 	Title:    "Fiber API documentation",
 	// }))
 
-	appLogic, err := internal.New(config.DataPath)
+	appLogic, err := internal.New(config)
 	if err != nil {
 		return fmt.Errorf("NewFlatnotes: %w", err)
 	}
-	// defer flatnotes.index.Close()
+	defer func() {
+		if err := appLogic.Close(); err != nil {
+			log.Println("close", err.Error())
+		}
+	}()
+
+	go func() {
+		if err := appLogic.Watch(ctx, config.ReconcileInterval); err != nil {
+			log.Println("watch", err.Error())
+		}
+	}()
+
+	go func() {
+		if err := appLogic.CleanTrash(ctx, config.TrashRetention); err != nil {
+			log.Println("clean trash", err.Error())
+		}
+	}()
+
+	if appLogic.Webhooks != nil {
+		go appLogic.Webhooks.Run(ctx, appLogic.Events)
+	}
+
+	frontend, err := newAssets(web.Dist, web.Embedded, config.PathPrefix)
+	if err != nil {
+		return fmt.Errorf("load frontend: %w", err)
+	}
+
+	setupApp(app.Group(config.PathPrefix), config, appLogic, frontend)
 
-	setupApp(app, config, appLogic)
+	ln, err := listen(ctx, config)
+	if err != nil {
+		return err
+	}
 
 	go func() {
 		<-ctx.Done()
+		// event streams would keep connections open
+		appLogic.Events.Close()
 		if err := app.ShutdownWithContext(ctx); err != nil {
 			log.Println("shutdown", err.Error())
 		}
 	}()
 
-	return app.Listen(":8080")
+	return app.Listener(ln)
+}
+
+// listen opens listener on configured unix socket or TCP address, serving TLS
+// if certificate is configured.
+func listen(ctx context.Context, config internal.Config) (net.Listener, error) {
+	var ln net.Listener
+	var err error
+	if config.SocketPath != "" {
+		// socket is left behind if previous run was killed
+		if stat, err := os.Stat(config.SocketPath); err == nil && stat.Mode().Type() == fs.ModeSocket {
+			if err := os.Remove(config.SocketPath); err != nil {
+				return nil, fmt.Errorf("remove stale socket: %w", err)
+			}
+		}
+
+		ln, err = net.Listen("unix", config.SocketPath)
+	} else {
+		ln, err = net.Listen("tcp", config.ListenAddress)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+
+	if config.TLSCertFile == "" {
+		return ln, nil
+	}
+
+	cert, err := internal.LoadCertificate(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	go func() {
+		if err := cert.Watch(ctx); err != nil {
+			log.Println("watch certificate", err.Error())
+		}
+	}()
+
+	return tls.NewListener(ln, &tls.Config{
+		GetCertificate: cert.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}), nil
+}
+
+// migrateImages moves inline base64 images of all notes into attachments.
+func migrateImages(ctx context.Context) error {
+	config := internal.NewConfig()
+	appLogic, err := internal.New(config)
+	if err != nil {
+		return fmt.Errorf("NewFlatnotes: %w", err)
+	}
+	defer func() {
+		if err := appLogic.Close(); err != nil {
+			log.Println("close", err.Error())
+		}
+	}()
+
+	if err := appLogic.WaitIndexed(ctx); err != nil {
+		return fmt.Errorf("wait for indexing: %w", err)
+	}
+
+	notes, images, err := appLogic.MigrateImages(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate images: %w", err)
+	}
+
+	log.Printf("moved %d images out of %d notes\n", images, notes)
+	return nil
 }
 
 func main() {
@@ -298,7 +921,18 @@ func main() {
 	defer cancel()
 
 	log.SetFlags(log.Lshortfile | log.Flags())
-	if err := run(ctx); err != nil {
+
+	cmd := run
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate-images":
+			cmd = migrateImages
+		default:
+			log.Fatalf("unknown command %q, available commands: migrate-images", os.Args[1])
+		}
+	}
+
+	if err := cmd(ctx); err != nil {
 		log.Fatalf("app stopped: %s", err.Error())
 	}
 }
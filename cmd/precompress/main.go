@@ -0,0 +1,109 @@
+// Precompress writes gzip and brotli compressed variants of text files in
+// directory next to them, as name.gz and name.br, so they are served without
+// compressing on each request. Variants not smaller than original are not
+// written.
+//
+//	go run ./cmd/precompress flatnotes/dist
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/andybalholm/brotli"
+)
+
+// _minSize is size of files too small to benefit from compression.
+const _minSize = 1 << 10
+
+// _compressible are extensions of files worth compressing.
+var _compressible = []string{
+	".html", ".js", ".css", ".map", ".json", ".webmanifest",
+	".svg", ".xml", ".txt", ".ico", ".ttf", ".eot",
+}
+
+// compressor writes compressed data to underlying writer.
+type compressor func(io.Writer) io.WriteCloser
+
+var _compressors = map[string]compressor{
+	".gz": func(w io.Writer) io.WriteCloser {
+		zw, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+		return zw
+	},
+	".br": func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.BestCompression)
+	},
+}
+
+func compress(content []byte, newWriter compressor) ([]byte, error) {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func precompress(dir string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !slices.Contains(_compressible, filepath.Ext(path)) {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if len(content) < _minSize {
+			return nil
+		}
+
+		for ext, newWriter := range _compressors {
+			compressed, err := compress(content, newWriter)
+			if err != nil {
+				return fmt.Errorf("compress %q: %w", path, err)
+			}
+
+			if len(compressed) >= len(content) {
+				continue
+			}
+
+			if err := os.WriteFile(path+ext, compressed, 0o644); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatalf("usage: %s DIR", os.Args[0])
+	}
+
+	count, err := precompress(os.Args[1])
+	if err != nil {
+		log.Fatalln("precompress:", err.Error())
+	}
+
+	log.Printf("written %d compressed files\n", count)
+}
@@ -0,0 +1,15 @@
+//go:build !embed
+
+package flatnotes
+
+import (
+	"io/fs"
+	"os"
+)
+
+// Embedded reports whether frontend is embedded into binary.
+const Embedded = false
+
+// Dist is built frontend, read from disk relative to working directory, so it
+// might be rebuilt without restarting server.
+var Dist fs.FS = os.DirFS("flatnotes/dist")
@@ -0,0 +1,23 @@
+//go:build embed
+
+package flatnotes
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed dist
+var dist embed.FS
+
+// Embedded reports whether frontend is embedded into binary.
+const Embedded = true
+
+// Dist is built frontend, embedded into binary.
+var Dist = func() fs.FS {
+	res, err := fs.Sub(dist, "dist")
+	if err != nil {
+		panic(err)
+	}
+	return res
+}()
@@ -0,0 +1,6 @@
+// Package flatnotes provides built frontend. It is embedded into binary built
+// with embed tag, otherwise it is read from disk.
+//
+// Frontend is built with npm run build, then precompressed variants of its
+// files are made with go run ./cmd/precompress flatnotes/dist.
+package flatnotes
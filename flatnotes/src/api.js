@@ -3,22 +3,33 @@ import EventBus from "./eventBus";
 import { getToken } from "./tokenStorage";
 
 export default function api(path, options) {
-    const {body, params, method} = options || {};
+    const {body, params, method, headers} = options || {};
 
     if (params) {
       path += "?" + new URLSearchParams(params);
     }
 
+    // multipart body gets its content type with boundary from fetch
+    const isForm = body instanceof FormData;
+
     let fetch_options = {
       method: method || (body ? "POST" : "GET"),
       headers: {
-        "Content-Type": "application/json",
+        ...(isForm ? {} : {"Content-Type": "application/json"}),
         "Authorization": (path !== "/api/token") ? `Bearer ${getToken()}` : undefined,
+        ...headers,
       },
-      body: body ? JSON.stringify(body) : undefined,
+      body: isForm ? body : (body ? JSON.stringify(body) : undefined),
     };
 
-    return fetch(path, fetch_options).catch((error) => {
+    return fetch(constants.pathPrefix + path, fetch_options).then((response) => {
+      if (!response.ok) {
+        const error = new Error(`${response.status} ${response.statusText}`);
+        error.response = response;
+        return Promise.reject(error);
+      }
+      return response;
+    }).catch((error) => {
       if (typeof error.response !== "undefined" && error.response.status === 401) {
         EventBus.$emit(
           "navigate",
@@ -29,5 +40,6 @@ export default function api(path, options) {
         error.handled = true;
       }
       return Promise.reject(error);
-    }).then((response) => response.json());
+    }).then((response) => response.text())
+      .then((text) => text ? JSON.parse(text) : null);
 }
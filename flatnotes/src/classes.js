@@ -1,10 +1,11 @@
 import * as constants from "./constants";
 
 class Note {
-  constructor(title, lastModified, content) {
+  constructor(title, lastModified, content, etag) {
     this.title = title;
     this.lastModified = lastModified;
     this.content = content;
+    this.etag = etag;
   }
 
   get href() {
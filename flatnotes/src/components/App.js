@@ -11,6 +11,7 @@ import SearchResults from "./SearchResults";
 import * as constants from "../constants";
 import * as helpers from "../helpers";
 import { clearToken, loadToken } from "../tokenStorage";
+import { subscribeNoteEvents } from "../noteEvents";
 import EventBus from "../eventBus";
 import api from "../api";
 
@@ -69,8 +70,10 @@ export default {
     },
 
     route: function () {
-      let path = window.location.pathname.split("/");
-      let basePath = `/${path[1]}`;
+      let path = window.location.pathname
+        .slice(constants.pathPrefix.length)
+        .split("/");
+      let basePath = `${constants.pathPrefix}/${path[1]}`;
       this.$bvModal.hide("search-modal");
       if (basePath == constants.basePaths.home) {
         this.updateDocumentTitle();
@@ -179,6 +182,7 @@ export default {
     this.loadConfig();
 
     loadToken();
+    subscribeNoteEvents();
 
     let darkTheme = localStorage.getItem("darkTheme");
     if (darkTheme != null) {
@@ -1,9 +1,13 @@
+// URL path prefix flatnotes is served under, e.g. "/notes", taken from base
+// of URLs set by server.
+export const pathPrefix = new URL(document.baseURI).pathname.replace(/\/$/, "");
+
 export const basePaths = {
-  home:   "/",
-  login:  "/login",
-  note:   "/note",
-  search: "/search",
-  new:    "/new",
+  home:   `${pathPrefix}/`,
+  login:  `${pathPrefix}/login`,
+  note:   `${pathPrefix}/note`,
+  search: `${pathPrefix}/search`,
+  new:    `${pathPrefix}/new`,
 };
 
 export const params = {
@@ -0,0 +1,19 @@
+import * as constants from "./constants";
+import EventBus from "./eventBus";
+
+let eventSource = null;
+
+// Changes of notes are streamed by server and emitted as "noteEvent". Event
+// source reconnects by itself, resuming from the last received event.
+export function subscribeNoteEvents() {
+  if (eventSource != null || typeof EventSource === "undefined") {
+    return;
+  }
+
+  eventSource = new EventSource(`${constants.pathPrefix}/api/events`);
+  ["created", "updated", "renamed", "deleted", "reset"].forEach(function (type) {
+    eventSource.addEventListener(type, function (event) {
+      EventBus.$emit("noteEvent", JSON.parse(event.data));
+    });
+  });
+}
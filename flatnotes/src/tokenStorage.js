@@ -1,7 +1,9 @@
+import * as constants from "./constants";
+
 const tokenStorageKey = "token";
 
 function getCookieString(token) {
-  return `${tokenStorageKey}=${token}; path=/attachments; SameSite=Strict`;
+  return `${tokenStorageKey}=${token}; path=${constants.pathPrefix || "/"}; SameSite=Strict`;
 }
 
 export function setToken(token, persist = false) {
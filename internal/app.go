@@ -1,14 +1,19 @@
 package internal
 
 import (
-	"cmp"
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"html"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/samber/lo"
@@ -17,11 +22,26 @@ import (
 )
 
 var (
-	ErrTitleExists  = fmt.Errorf("The specified title already exists.")
-	ErrTitleInvalid = fmt.Errorf("The specified title contains invalid characters.")
-	ErrNotFound     = fmt.Errorf("The specified note cannot be found.")
+	ErrTitleExists     = fmt.Errorf("The specified title already exists.")
+	ErrTitleInvalid    = fmt.Errorf("The specified title contains invalid characters.")
+	ErrNotFound        = fmt.Errorf("The specified note cannot be found.")
+	ErrVersionMismatch = fmt.Errorf("The note has been changed since it was loaded.")
 )
 
+// VersionConflictError is returned when note is changed with outdated
+// version, it carries current version of note.
+type VersionConflictError struct {
+	Current NoteContentResponseModel
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("note %q version mismatch, current version is %s", e.Current.Title, e.Current.ETag)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionMismatch
+}
+
 var (
 	_reTags       = regexp.MustCompile(`(?:^#|\s#)(\w+)(?:\s|$)`)
 	_reCodeblocks = regexp.MustCompile("`{1,3}.*?`{1,3}" /*, re.DOTALL*/)
@@ -34,6 +54,18 @@ func isValidTitle(title string) bool {
 	return !strings.ContainsAny(title, _invalidChars)
 }
 
+// Return False if the declared path is not a relative slash separated path of
+// valid titles. Hidden files and directories, "." and ".." are not allowed, so
+// path cannot point outside of notes directory.
+func isValidPath(path string) bool {
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "" || strings.HasPrefix(segment, ".") || !isValidTitle(segment) {
+			return false
+		}
+	}
+	return true
+}
+
 // Similar to re.sub but returns a tuple of:
 //
 // - `string` with matches removed
@@ -61,73 +93,202 @@ func extractTags(content string) (string, Set[string]) {
 	return contentExTags, tagsSet
 }
 
+// _staticDir is directory in notes directory served as static files.
+const _staticDir = "static"
+
 func stripExt(filename string) string {
 	_, fname := filepath.Split(filename)
 	name, _ := strings.CutSuffix(fname, _markdownExt)
 	return name
 }
 
+// _indexVersion is version of NoteDocument structure and fields stored in
+// index. Bump it to rebuild saved indexes on next startup.
+const _indexVersion = 3
+
 type App struct {
-	Dir   string
-	Index *fts.Index[NoteDocument]
+	Dir      string
+	IndexDir string
+	// Recursive mode, notes are searched in subdirectories too and their
+	// titles are slash separated paths relative to Dir.
+	Recursive bool
+	Index     *fts.Index[NoteDocument]
+	// Links between notes
+	links *linkGraph
+	// Previous versions of notes changed through App
+	History History
+	// Git repository of notes directory, nil if git mode is disabled
+	Git *Git
+	// Changes of notes, nil if they are not published
+	Events *Events
+	// Webhooks receiving changes of notes, nil if there are none
+	Webhooks *Webhooks
+	// Notes whose index entries are found outdated, to be synced
+	resync chan string
+	// Serializes changes of notes made through App, so that notes are not
+	// changed between version check and write. Locked before indexMu.
+	writeMu sync.Mutex
+	// Serializes index writers
+	indexMu sync.Mutex
+	// Coalesces full index syncs
+	reconcile coalescer
+	// Initial indexing, nil if index is ready from start
+	warmup *warmup
+	// Maximum size of uploaded attachment in bytes
+	AttachmentMaxSize int64
+	// MIME types of attachments allowed to upload
+	AttachmentTypes []string
+	// Whether inline base64 images are moved to attachments on save
+	ExtractImages bool
+}
+
+// validTitle checks whether title is valid note title in current mode.
+func (app *App) validTitle(title string) bool {
+	if app.Recursive {
+		return isValidPath(title)
+	}
+	return isValidTitle(title)
 }
 
-func New(dir string) (App, error) {
-	if stat, err := os.Stat(dir); os.IsNotExist(err) {
-		return App{}, fmt.Errorf("not a directory: %q does not exist", dir)
+func New(config Config) (*App, error) {
+	dir := config.DataPath
+	if stat, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("not a directory: %q does not exist", dir)
 	} else if !stat.IsDir() {
-		return App{}, fmt.Errorf("not a directory: %q is not a directory", dir)
+		return nil, fmt.Errorf("not a directory: %q is not a directory", dir)
+	}
+
+	if err := os.MkdirAll(config.IndexPath, 0o755); err != nil {
+		return nil, fmt.Errorf("create index directory: %w", err)
+	}
+
+	res := &App{
+		Dir:       dir,
+		IndexDir:  config.IndexPath,
+		Recursive: config.Recursive,
+		Index:     fts.NewIndex[NoteDocument](),
+		links:     newLinkGraph(),
+		History:   newFSHistory(dir, config.HistoryMaxVersions, config.HistoryMaxAge),
+		resync:    make(chan string, _resyncQueueSize),
+
+		AttachmentMaxSize: config.AttachmentMaxSize,
+		AttachmentTypes:   config.AttachmentTypes,
+		ExtractImages:     config.ExtractImages,
+	}
+
+	if config.Git {
+		git, err := newGit(dir, config.GitCommitDelay)
+		if err != nil {
+			return nil, fmt.Errorf("init git: %w", err)
+		}
+
+		res.Git = git
+		res.History = git
 	}
 
-	res := App{
-		Dir:   dir,
-		Index: fts.NewIndex[NoteDocument](),
+	if config.WebhooksPath != "" {
+		hooks, err := loadWebhooks(config.WebhooksPath)
+		if err != nil {
+			return nil, fmt.Errorf("load webhooks: %w", err)
+		}
+
+		res.Webhooks = newWebhooks(hooks, filepath.Join(config.IndexPath, "webhooks.log"))
 	}
 
-	// for now loaded from fs on startup
-	start := time.Now()
-	log.Println("started initial indexing")
-	if err := res.updateIndex(); err != nil {
-		return App{}, fmt.Errorf("update index: %w", err)
+	if err := res.History.Prune(); err != nil {
+		return nil, fmt.Errorf("prune history: %w", err)
 	}
-	log.Println("finished initial indexing in", time.Since(start))
+
+	res.Events = newEvents()
+	res.startWarmup()
 
 	return res, nil
 }
 
+func (app *App) indexFilepath() string {
+	return filepath.Join(app.IndexDir, "index.gob")
+}
+
+func (app *App) loadIndex() error {
+	f, err := os.Open(app.indexFilepath())
+	if err != nil {
+		return fmt.Errorf("open index: %w", err)
+	}
+	defer f.Close()
+
+	return app.Index.Load(bufio.NewReader(f), _indexVersion)
+}
+
+// saveIndex writes index to temporary file which then replaces the previous
+// one, so index file is never left half written.
+func (app *App) saveIndex() error {
+	f, err := os.CreateTemp(app.IndexDir, "index-*.gob")
+	if err != nil {
+		return fmt.Errorf("create index file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if err := app.Index.Save(w, _indexVersion); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("write index file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close index file: %w", err)
+	}
+
+	return os.Rename(f.Name(), app.indexFilepath())
+}
+
+// Close saves index, so it is not rebuilt on next startup, and commits pending
+// changes. Unfinished initial indexing is stopped.
+func (app *App) Close() error {
+	app.stopWarmup()
+
+	if app.Git != nil {
+		if err := app.Git.Flush(); err != nil {
+			return fmt.Errorf("commit pending updates: %w", err)
+		}
+	}
+
+	return app.saveIndex()
+}
+
 type SearchResult struct {
-	Note
+	Title                              string
+	LastModified                       time.Time
 	Score                              float64
 	TitleHighlights, ContentHighlights string
 	TagMatches                         []string
 }
 
-func (app *App) newSearchResult(hit fts.Hit[NoteDocument]) (SearchResult, error) {
-	note, err := app.getNote(hit.Doc.ID())
-	if err != nil {
-		return SearchResult{}, fmt.Errorf("get note %q: %w", hit.Doc.ID(), err)
-	}
-
+// newSearchResult makes search result from indexed document only, so results
+// are consistent with search even if notes change meanwhile.
+func (app *App) newSearchResult(hit fts.Hit[NoteDocument]) SearchResult {
 	// If the search was ordered using a text field then hit.score is the
 	// value of that field. This isn't useful so only set _score if it
 	// is a float.
 
+	mark := func(s string) string {
+		return "<mark>" + s + "</mark>"
+	}
+
 	var titleHighlights, contentHighlights string
-	for _, field := range hit.Terms {
-		re := regexp.MustCompile(`\b(?i)` + regexp.QuoteMeta(field.Term) + `\b`)
-		// switch k {
-		// case "Title":
-		// 	titleHighlights += strings.Join(field, "\n")
-		// case "Content":
-		//	contentHighlights += strings.Join(field, "\n")
-		contentHighlights += re.ReplaceAllStringFunc(hit.Doc.Content, func(s string) string {
-			return "<mark>" + s + "</mark>"
-		})
-		// case "Tags":
-		// 	tagMatches += strings.Join(field, "\n")
-		// default:
-		// 	log.Printf("unknown field: %v\n", field)
-		// }
+	for _, term := range hit.Terms {
+		re := regexp.MustCompile(`\b(?i)` + regexp.QuoteMeta(term) + `\b`)
+		contentHighlights += re.ReplaceAllStringFunc(hit.Doc.Content, mark)
+	}
+	if len(hit.Terms) > 0 {
+		// title is shown as html, all terms are marked in it at once
+		re := regexp.MustCompile(`\b(?i)(` + strings.Join(lo.Map(hit.Terms, func(term string, _ int) string {
+			return regexp.QuoteMeta(html.EscapeString(term))
+		}), "|") + `)\b`)
+		titleHighlights = re.ReplaceAllStringFunc(html.EscapeString(hit.Doc.Title), mark)
 	}
 
 	replacer := strings.NewReplacer(
@@ -147,16 +308,37 @@ func (app *App) newSearchResult(hit fts.Hit[NoteDocument]) (SearchResult, error)
 		return replacer.Replace(strings.Join(lines, "<br>"))
 	}
 
+	// tags are indexed both as is and analyzed
+	tagMatches := lo.Filter(lo.Keys(hit.Doc.Tags), func(tag string, _ int) bool {
+		return slices.Contains(hit.Terms, tag) || lo.Some(hit.Terms, fts.Terms(tag))
+	})
+	slices.Sort(tagMatches)
+
 	return SearchResult{
-		Note:              note,
+		Title:             hit.Doc.Title,
+		LastModified:      hit.Doc.Modtime,
 		Score:             hit.Score,
 		TitleHighlights:   postProcessHighlight(titleHighlights),
 		ContentHighlights: postProcessHighlight(contentHighlights),
-		TagMatches:        hit.Tags,
-	}, nil
+		TagMatches:        tagMatches,
+	}
+}
+
+// checkIndexed reports whether note of indexed document still exists. Notes
+// changed since indexing are scheduled for sync.
+func (app *App) checkIndexed(doc NoteDocument) bool {
+	stat, err := os.Stat(noteFilepath(app.Dir, doc.Title))
+	if err != nil || !stat.ModTime().Equal(doc.Modtime) || stat.Size() != doc.Size {
+		app.scheduleSync(doc.Title)
+	}
+	return err == nil
 }
 
 func (app *App) getNote(title string) (Note, error) {
+	if !app.validTitle(title) {
+		return Note{}, ErrTitleInvalid
+	}
+
 	filepath := noteFilepath(app.Dir, title)
 	if !ospathexists(filepath) {
 		return Note{}, ErrNotFound
@@ -171,6 +353,10 @@ func (app *App) getNote(title string) (Note, error) {
 // Return a list containing a Note object for every file in the notes
 // directory.
 func (app *App) getNotes() ([]Note, error) {
+	if app.Recursive {
+		return app.getNotesRecursive()
+	}
+
 	matches, err := filepath.Glob(filepath.Join(app.Dir, "*"+_markdownExt))
 	if err != nil {
 		return nil, fmt.Errorf("glob: %w", err)
@@ -189,38 +375,81 @@ func (app *App) getNotes() ([]Note, error) {
 	return res, nil
 }
 
-// Synchronize the index with the notes directory.
-// TODO: optimize
+// skipDir reports whether notes are not looked for in directory with given
+// name in recursive mode.
+func skipDir(name string) bool {
+	// hidden directories contain index, history, trash and other service
+	// data, static one contains attachments
+	return strings.HasPrefix(name, ".") || name == _staticDir
+}
+
+func (app *App) getNotesRecursive() ([]Note, error) {
+	res := []Note{}
+	if err := filepath.WalkDir(app.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != app.Dir && skipDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		title, ok := app.titleFromPath(path)
+		if !ok {
+			return nil
+		}
+
+		note, err := app.getNote(title)
+		if err != nil {
+			return fmt.Errorf("new note %q: %w", path, err)
+		}
+
+		res = append(res, note)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walk: %w", err)
+	}
+	return res, nil
+}
+
+// updateIndex synchronizes index with notes directory. Concurrent calls are
+// coalesced, so that directory is not walked by several of them at once.
 func (app *App) updateIndex() error {
+	return app.reconcile.Do(app.reconcileIndex)
+}
+
+// reconcileIndex synchronizes index with notes directory.
+func (app *App) reconcileIndex() error {
 	indexed := Set[string]{}
-	docs := []NoteDocument{}
-	for id, doc := range app.Index.Documents {
-		idxFilename := id + _markdownExt
-		idxFilepath := filepath.Join(app.Dir, idxFilename)
-		if _, err := os.Stat(idxFilepath); os.IsNotExist(err) {
-			// Delete missing
-			app.Index.Remove(id)
+	for id, doc := range app.Index.Documents() {
+		indexed[id] = struct{}{}
+
+		if !app.validTitle(id) {
+			// not available in current mode
+			app.indexMu.Lock()
+			app.indexRemove(id)
+			app.indexMu.Unlock()
 			log.Println(id, "removed from index")
-		} else if stat, err := os.Stat(idxFilepath); err == nil && stat.ModTime().After(doc.Modtime) {
-			note, err := app.getNote(id)
-			if err != nil {
-				return fmt.Errorf("get note %q: %w", id, err)
-			}
-
-			doc, err := toDocument(note)
-			if err != nil {
-				return fmt.Errorf("get document, %q: %w", note.Title, err)
-			}
+			continue
+		}
 
-			docs = append(docs, doc)
+		stat, statErr := os.Stat(noteFilepath(app.Dir, id))
+		if statErr == nil && stat.ModTime().Equal(doc.Modtime) && stat.Size() == doc.Size {
+			// Ignore already indexed
+			continue
+		}
 
-			// Update modified
-			log.Println(id, "updated")
+		if err := app.syncNote(id); err != nil {
+			return err
+		}
 
-			indexed[id] = struct{}{}
+		if statErr != nil {
+			log.Println(id, "removed from index")
 		} else {
-			// Ignore already indexed
-			indexed[id] = struct{}{}
+			log.Println(id, "updated")
 		}
 	}
 
@@ -235,29 +464,47 @@ func (app *App) updateIndex() error {
 			continue
 		}
 
-		doc, err := toDocument(note)
-		if err != nil {
-			return fmt.Errorf("get document, %q: %w", note.Title, err)
+		if err := app.syncNote(note.Title); err != nil {
+			return err
 		}
 
-		docs = append(docs, doc)
-
 		log.Printf("%q added to index\n", note.Title)
 	}
 
-	app.Index.Add(docs...)
+	return nil
+}
+
+// syncNote updates index entry of note from notes directory, removing it if
+// note file is gone.
+func (app *App) syncNote(title string) error {
+	// note is read under lock, so it never replaces more recent version
+	app.indexMu.Lock()
+	defer app.indexMu.Unlock()
+
+	note, err := app.getNote(title)
+	if errors.Is(err, ErrNotFound) {
+		app.indexRemove(title)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get note %q: %w", title, err)
+	}
+
+	doc, err := toDocument(note)
+	if errors.Is(err, fs.ErrNotExist) {
+		app.indexRemove(title)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get document, %q: %w", title, err)
+	}
 
+	app.indexAdd(doc)
 	return nil
 }
 
 // Return a list of all indexed tags.
 func (app *App) GetTags() (Set[string], error) {
-	if err := app.updateIndex(); err != nil {
-		return nil, err
-	}
-
 	res := Set[string]{}
-	for _, note := range app.Index.Documents {
+	for _, note := range app.Index.Documents() {
 		for tag := range note.Tags {
 			res[tag] = struct{}{}
 		}
@@ -282,15 +529,71 @@ const (
 	OrderDesc Order = "desc"
 )
 
-// Search the index for the given term.
+// Field prefixes allowed in search queries mapped to NoteDocument fields.
+var _searchFields = map[string]string{
+	"title":   "Title",
+	"content": "Content",
+	"tag":     "Tags",
+	"tags":    "Tags",
+	"alias":   "Aliases",
+	"aliases": "Aliases",
+}
+
+// searchField resolves field prefix in search query to NoteDocument field.
+// Front matter keys are available with "meta." prefix, e.g. "meta.author".
+func searchField(name string) (string, bool) {
+	name = strings.ToLower(name)
+	if field, ok := _searchFields[name]; ok {
+		return field, true
+	}
+
+	if key, ok := strings.CutPrefix(name, _metaFieldPrefix); ok && key != "" {
+		return name, true
+	}
+
+	return "", false
+}
+
+// searchCursor is a position in search results, it contains sort keys of the
+// last returned hit.
+type searchCursor struct {
+	Sort    Sort      `json:"s"`
+	Order   Order     `json:"o"`
+	Score   float64   `json:"sc"`
+	Modtime time.Time `json:"m"`
+	Title   string    `json:"t"`
+}
+
+// Search the index for the given term. Results are returned in pages of at
+// most limit hits starting after cursor, all hits are returned if limit is not
+// positive. If folder is not empty, only notes inside it are returned.
 func (app *App) Search(
 	phrase string,
 	sortt Sort,
 	order Order,
 	limit int,
-) ([]SearchResultModel, error) {
-	if err := app.updateIndex(); err != nil {
-		return nil, fmt.Errorf("update index: %w", err)
+	cursor string,
+	folder string,
+) (SearchResponseModel, error) {
+	var after *fts.Hit[NoteDocument]
+	if cursor != "" {
+		var position searchCursor
+		if err := decodeCursor(cursor, &position); err != nil {
+			return SearchResponseModel{}, err
+		}
+
+		// cursor made for another sort mode points nowhere
+		if position.Sort != sortt || position.Order != order {
+			return SearchResponseModel{}, ErrCursorInvalid
+		}
+
+		after = &fts.Hit[NoteDocument]{
+			Doc: NoteDocument{
+				Title:   position.Title,
+				Modtime: position.Modtime,
+			},
+			Score: position.Score,
+		}
 	}
 
 	phrase = strings.TrimSpace(phrase)
@@ -298,7 +601,7 @@ func (app *App) Search(
 	var hits []fts.Hit[NoteDocument]
 	// Parse Query
 	if phrase == "*" {
-		hits = lo.MapToSlice(app.Index.Documents, func(_ string, doc NoteDocument) fts.Hit[NoteDocument] {
+		hits = lo.MapToSlice(app.Index.Documents(), func(_ string, doc NoteDocument) fts.Hit[NoteDocument] {
 			return fts.Hit[NoteDocument]{
 				Doc:   doc,
 				Score: 0,
@@ -306,67 +609,65 @@ func (app *App) Search(
 			}
 		})
 	} else {
-		// Determine Sort Direction
-		// Note: Confusingly, when sorting by 'score', reverse = True means
-		// asc so we have to flip the logic for that case!
-		// reverse := order == "desc"
-		// if sort == SortNone {
-		// 	reverse = !reverse
-		// }
-
-		// Run Search
-		hits = app.Index.Search(
-			phrase,
-			// /*sortedby=*/ sort,
-			// /*reverse=*/ reverse,
-			// /*limit=*/ limit,
-			// /*terms=*/ true,
-			func() []string {
-				_, tags := extractTags(phrase)
-				return lo.Keys(tags)
-			}(),
-		)
-	}
-
-	slices.SortFunc(hits, func(i, j fts.Hit[NoteDocument]) int {
-		if i.Score != j.Score {
-			return cmp.Compare(j.Score, i.Score)
+		query, err := fts.ParseQuery(phrase, searchField)
+		if err != nil {
+			return SearchResponseModel{}, fmt.Errorf("parse query: %w", err)
 		}
 
-		return cmp.Compare(j.Doc.Modtime.Unix(), i.Doc.Modtime.Unix())
-	})
+		// Run Search
+		hits = app.Index.Search(query)
+	}
 
-	if limit > 0 {
-		hits = lo.Slice(hits, 0, limit)
+	if folder = strings.Trim(folder, "/"); folder != "" {
+		hits = lo.Filter(hits, func(hit fts.Hit[NoteDocument], _ int) bool {
+			return strings.HasPrefix(hit.Doc.Title, folder+"/")
+		})
 	}
 
-	res := []SearchResultModel{}
-	for _, hit := range hits {
-		searchRes, err := app.newSearchResult(hit)
-		if err != nil {
-			return nil, fmt.Errorf("map search result %v: %w", hit, err)
-		}
+	compare := hitsComparator(sortt, order)
+	slices.SortFunc(hits, compare)
 
-		modtime, err := searchRes.LastModified()
-		if err != nil {
-			return nil, fmt.Errorf("get last modified time %q: %w", searchRes.Title, err)
+	page, more := paginate(hits, compare, after, limit)
+
+	res := SearchResponseModel{
+		Results: []SearchResultModel{},
+		Total:   len(hits),
+		Partial: !app.Indexed(),
+	}
+	for _, hit := range page {
+		// deleted notes are dropped, changed ones are shown as indexed
+		if !app.checkIndexed(hit.Doc) {
+			continue
 		}
 
+		searchRes := app.newSearchResult(hit)
+
 		toOption := func(s string) *string {
 			if s == "" {
 				return nil
 			}
 			return &s
 		}
-		res = append(res, SearchResultModel{
+		res.Results = append(res.Results, SearchResultModel{
 			Score:             searchRes.Score,
 			Title:             searchRes.Title,
-			LastModified:      modtime.Unix(),
+			LastModified:      searchRes.LastModified.Unix(),
 			TitleHighlights:   toOption(searchRes.TitleHighlights),
 			ContentHighlights: toOption(searchRes.ContentHighlights),
 			TagMatches:        searchRes.TagMatches,
 		})
 	}
+
+	if more {
+		last := page[len(page)-1]
+		res.NextCursor = lo.ToPtr(encodeCursor(searchCursor{
+			Sort:    sortt,
+			Order:   order,
+			Score:   last.Score,
+			Modtime: last.Doc.Modtime,
+			Title:   last.Doc.Title,
+		}))
+	}
 	return res, nil
 }
 
@@ -381,13 +682,15 @@ func (app *App) GetNote(title string, includeContent bool) (NoteContentResponseM
 		return NoteContentResponseModel{}, fmt.Errorf("get last modified time %q: %w", title, err)
 	}
 
+	content, err := note.GetContent()
+	if err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("get content: %w", err)
+	}
+
+	metadata, _, _ := parseFrontMatter(string(content))
+
 	resContent := (*string)(nil)
 	if includeContent {
-		content, err := note.GetContent()
-		if err != nil {
-			return NoteContentResponseModel{}, fmt.Errorf("get content: %w", err)
-		}
-
 		resContent = lo.ToPtr(string(content))
 	}
 
@@ -396,69 +699,224 @@ func (app *App) GetNote(title string, includeContent bool) (NoteContentResponseM
 			Title:        note.Title,
 			LastModified: modtime.Unix(),
 		},
-		Content: resContent,
+		Content:  resContent,
+		Metadata: &metadata,
+		ETag:     contentETag(content),
 	}, nil
 }
 
-func (app *App) CreateNote(data NotePostModel) (NoteContentResponseModel, error) {
-	if !isValidTitle(data.Title) {
+func (app *App) CreateNote(ctx context.Context, data NotePostModel) (NoteContentResponseModel, error) {
+	if !app.validTitle(data.Title) {
 		return NoteContentResponseModel{}, ErrTitleInvalid
 	}
 
-	note, lastModified, err := createNote(app.Dir, data.Title, data.Content)
+	app.writeMu.Lock()
+	defer app.writeMu.Unlock()
+
+	content, err := app.prepareContent(data.Content)
+	if err != nil {
+		return NoteContentResponseModel{}, err
+	}
+
+	note, lastModified, err := createNote(app.Dir, data.Title, content)
 	if err != nil {
 		return NoteContentResponseModel{}, err
 	}
 
+	app.saveVersion(note.Title, nil, []byte(content), HistoryActionCreate)
+
+	if err := app.syncNote(note.Title); err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("index note %q: %w", note.Title, err)
+	}
+
+	app.commit(ctx, "Create "+note.Title, note.Title)
+
 	return NoteContentResponseModel{
 		NoteResponseModel: NoteResponseModel{
 			Title:        note.Title,
 			LastModified: lastModified.Unix(),
 		},
-		Content: &data.Content,
+		Content: &content,
+		ETag:    contentETag([]byte(content)),
 	}, nil
 }
 
-func (app *App) UpdateNote(title string, data NotePatchModel) (NoteContentResponseModel, error) {
-	if !isValidTitle(*data.NewTitle) {
+// checkVersion returns VersionConflictError if ifMatch, value of If-Match
+// header, does not match current version of note. Empty ifMatch matches any
+// version. Must be called with writeMu locked, until note is changed.
+func (app *App) checkVersion(title, ifMatch string) error {
+	if ifMatch == "" {
+		return nil
+	}
+
+	current, err := app.GetNote(title, true)
+	if err != nil {
+		return err
+	}
+
+	if !etagMatches(ifMatch, current.ETag) {
+		return &VersionConflictError{Current: current}
+	}
+
+	return nil
+}
+
+// UpdateNote changes note title and/or content. If ifMatch is not empty, note
+// is changed only if its current version matches it.
+func (app *App) UpdateNote(ctx context.Context, title string, data NotePatchModel, ifMatch string) (NoteContentResponseModel, error) {
+	if data.NewTitle != nil && !app.validTitle(*data.NewTitle) {
 		return NoteContentResponseModel{}, ErrTitleInvalid
 	}
 
+	app.writeMu.Lock()
+	defer app.writeMu.Unlock()
+
 	note, err := app.getNote(title)
 	if err != nil {
 		return NoteContentResponseModel{}, fmt.Errorf("get note %q: %w", title, err)
 	}
 
+	renamed := data.NewTitle != nil && *data.NewTitle != title
+	if renamed && titleTaken(noteFilepath(app.Dir, title), noteFilepath(app.Dir, *data.NewTitle)) {
+		return NoteContentResponseModel{}, ErrTitleExists
+	}
+
+	if err := app.checkVersion(title, ifMatch); err != nil {
+		return NoteContentResponseModel{}, err
+	}
+
+	content, err := note.GetContent()
+	if err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("get note %q content: %w", title, err)
+	}
+
+	if data.NewContent != nil {
+		newContent, err := app.prepareContent(*data.NewContent)
+		if err != nil {
+			return NoteContentResponseModel{}, err
+		}
+		data.NewContent = &newContent
+	}
+
+	// links of renamed note itself are rewritten along with links to it, as
+	// relative paths change if note moves to other directory
+	rewrites := []linkRewrite{}
+	if renamed && data.UpdateLinks {
+		newContent := string(content)
+		if data.NewContent != nil {
+			newContent = *data.NewContent
+		}
+
+		if newContent, changed := rewriteLinks(newContent, title, *data.NewTitle, title, *data.NewTitle); changed {
+			data.NewContent = &newContent
+		}
+
+		rewrites, err = app.prepareLinkRewrites(title, *data.NewTitle)
+		if err != nil {
+			return NoteContentResponseModel{}, err
+		}
+		// applied rewrites have no temporary files anymore
+		defer discardLinkRewrites(rewrites)
+	}
+
+	if renamed {
+		app.flushCommits()
+	}
+	// links are rewritten before rename, so that failure to rewrite any of
+	// them leaves all notes as they were
+	if err := app.applyLinkRewrites(rewrites); err != nil {
+		return NoteContentResponseModel{}, err
+	}
 	if data.NewTitle != nil {
 		if err := note.SetTitle(*data.NewTitle); err != nil {
+			revertLinkRewrites(rewrites)
 			return NoteContentResponseModel{}, fmt.Errorf("set note %q title to %q: %w", title, *data.NewTitle, err)
 		}
 	}
+	if renamed {
+		if err := app.History.Rename(title, note.Title); err != nil {
+			return NoteContentResponseModel{}, fmt.Errorf("move note %q history: %w", title, err)
+		}
+	}
 	if data.NewContent != nil {
 		if err := note.SetContent([]byte(*data.NewContent)); err != nil {
 			return NoteContentResponseModel{}, fmt.Errorf("set note %q content: %w", title, err)
 		}
 	}
 
+	switch {
+	case renamed:
+		app.saveVersion(note.Title, content, []byte(lo.FromPtrOr(data.NewContent, string(content))), HistoryActionRename)
+	case data.NewContent != nil && *data.NewContent != string(content):
+		app.saveVersion(note.Title, content, []byte(*data.NewContent), HistoryActionUpdate)
+	}
+
+	app.indexMu.Lock()
 	doc, err := toDocument(note)
 	if err != nil {
+		app.indexMu.Unlock()
 		return NoteContentResponseModel{}, fmt.Errorf("get note data %q: %w", title, err)
 	}
 
+	if note.Title != title {
+		app.indexRename(title, doc)
+	} else {
+		app.indexAdd(doc)
+	}
+	app.indexMu.Unlock()
+
+	updated := app.finishLinkRewrites(rewrites)
+
+	if renamed {
+		app.commit(ctx, fmt.Sprintf("Rename %s to %s", title, note.Title), append([]string{title, note.Title}, updated...)...)
+	} else {
+		app.commitUpdate(ctx, title)
+	}
+
 	return NoteContentResponseModel{
 		NoteResponseModel: NoteResponseModel{
 			Title:        note.Title,
 			LastModified: doc.Modtime.Unix(),
 		},
-		Content: lo.ToPtr(doc.Content),
+		Content:      lo.ToPtr(doc.Content),
+		ETag:         contentETag([]byte(doc.Content)),
+		UpdatedNotes: updated,
 	}, nil
 }
 
-func (app *App) DeleteNote(title string) error {
+// DeleteNote moves note to trash. If ifMatch is not empty, note is deleted
+// only if its current version matches it.
+func (app *App) DeleteNote(ctx context.Context, title string, ifMatch string) error {
+	app.writeMu.Lock()
+	defer app.writeMu.Unlock()
 	note, err := app.getNote(title)
 	if err != nil {
 		return err
 	}
 
-	return note.Delete()
+	if err := app.checkVersion(title, ifMatch); err != nil {
+		return err
+	}
+
+	content, err := note.GetContent()
+	if err != nil {
+		return fmt.Errorf("get note %q content: %w", title, err)
+	}
+
+	// deleted note version has its last content
+	if err := app.History.Save(title, nil, content, HistoryActionDelete); err != nil {
+		return fmt.Errorf("save note %q version: %w", title, err)
+	}
+
+	app.flushCommits()
+	if err := app.trashNote(note); err != nil {
+		return fmt.Errorf("move note %q to trash: %w", title, err)
+	}
+
+	app.indexMu.Lock()
+	app.indexRemove(title)
+	app.indexMu.Unlock()
+
+	app.commit(ctx, "Delete "+title, title)
+	return nil
 }
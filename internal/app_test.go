@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rprtr258/flatnotes/internal/fts"
+)
+
+// newTestApp returns App with notes and index in temporary directories.
+func newTestApp(t *testing.T) *App {
+	dir := t.TempDir()
+	return &App{
+		Dir:      dir,
+		IndexDir: t.TempDir(),
+		Index:    fts.NewIndex[NoteDocument](),
+		links:    newLinkGraph(),
+		History:  newFSHistory(dir, 0, 0),
+		Events:   newEvents(),
+		resync:   make(chan string, _resyncQueueSize),
+	}
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+	app.History = newFSHistory(app.Dir, 10, 0)
+	dir := app.Dir
+
+	const notes = 8
+	for i := 0; i < notes; i++ {
+		_, err := app.CreateNote(ctx, NotePostModel{Title: fmt.Sprint("note", i), Content: "#tag word"})
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		for i := 0; i < notes; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 20; j++ {
+					f(i)
+				}
+			}(i)
+		}
+	}
+
+	run(func(i int) {
+		_, err := app.UpdateNote(ctx, fmt.Sprint("note", i), NotePatchModel{NewContent: lo.ToPtr(fmt.Sprint("#tag word [[note", (i+1)%notes, "]]"))}, "")
+		assert.NoError(t, err)
+	})
+	run(func(i int) {
+		// external edits
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprint("external", i, ".md")), []byte("word"), 0o644))
+		assert.NoError(t, app.syncNote(fmt.Sprint("external", i)))
+	})
+	run(func(int) {
+		_, err := app.Search("word", SortScore, OrderDesc, 5, "", "")
+		assert.NoError(t, err)
+		_, err = app.Search("*", SortTitle, OrderAsc, 0, "", "")
+		assert.NoError(t, err)
+	})
+	run(func(i int) {
+		_, err := app.GetTags()
+		assert.NoError(t, err)
+		_, err = app.GetBacklinks(fmt.Sprint("note", i), 0, "")
+		assert.NoError(t, err)
+		_, err = app.GetUnresolvedLinks(0, "")
+		assert.NoError(t, err)
+	})
+	run(func(int) {
+		assert.NoError(t, app.updateIndex())
+	})
+	wg.Wait()
+
+	require.NoError(t, app.updateIndex())
+	assert.Equal(t, 2*notes, app.Index.Len())
+	for i := 0; i < notes; i++ {
+		assert.Len(t, app.links.Backlinks(fmt.Sprint("note", i)), 1)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	app := newTestApp(t)
+	dir := app.Dir
+
+	const notes = 50
+	for i := 0; i < notes; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprint("note", i, ".md")), []byte("#tag word [[note0]]"), 0o644))
+	}
+	// saved index is outdated
+	app.Index.Add(NoteDocument{Title: "deleted", Content: "word"})
+
+	_, events, unsubscribe := app.Events.Subscribe("")
+	defer unsubscribe()
+
+	app.startWarmup()
+	require.NoError(t, app.WaitIndexed(context.Background()))
+
+	assert.Equal(t, StatusModel{Notes: notes, Done: notes, Total: notes}, app.GetStatus())
+	_, ok := app.Index.Get("deleted")
+	assert.False(t, ok)
+	assert.Len(t, app.links.Backlinks("note0"), notes)
+
+	res, err := app.Search("word", SortScore, OrderDesc, 0, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, notes, res.Total)
+	assert.False(t, res.Partial)
+
+	// changes found on startup are not published
+	assert.Empty(t, events)
+}
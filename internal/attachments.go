@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrAttachmentTooLarge   = fmt.Errorf("The attachment is too large.")
+	ErrAttachmentNotAllowed = fmt.Errorf("The attachment file type is not allowed.")
+	ErrAttachmentNotFound   = fmt.Errorf("The attachment cannot be found.")
+)
+
+// attachmentsDir returns directory where attachments are stored, it is served
+// as static files.
+func (app *App) attachmentsDir() string {
+	return filepath.Join(app.Dir, _staticDir)
+}
+
+// sanitizeFilename makes filename safe to store in attachments directory.
+func sanitizeFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, `\`, "/"))
+	filename = strings.Map(func(r rune) rune {
+		if !isValidTitle(string(r)) {
+			return '_'
+		}
+		return r
+	}, filename)
+	filename = strings.TrimLeft(filename, ".")
+	if filename == "" {
+		return "attachment"
+	}
+	return filename
+}
+
+// allowedType reports whether MIME type matches any of allowed types, which
+// might contain wildcard subtype, e.g. "image/*".
+func allowedType(contentType string, allowed []string) bool {
+	return slices.ContainsFunc(allowed, func(pattern string) bool {
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			return strings.HasPrefix(contentType, prefix+"/")
+		}
+		return contentType == pattern
+	})
+}
+
+// newAttachmentModel describes attachment. Links to it are relative, so that
+// they are resolved against base URL of frontend, which is served under path
+// prefix, and notes do not depend on it.
+func (app *App) newAttachmentModel(filename string, size int64, contentType string) AttachmentModel {
+	link := _staticDir + "/" + url.PathEscape(filename)
+	markdown := fmt.Sprintf("[%s](%s)", filename, link)
+	if strings.HasPrefix(contentType, "image/") {
+		markdown = "!" + markdown
+	}
+
+	return AttachmentModel{
+		Filename:    filename,
+		URL:         link,
+		Markdown:    markdown,
+		Size:        size,
+		ContentType: contentType,
+	}
+}
+
+// detectContentType returns MIME type of file by its content, falling back to
+// its extension.
+func detectContentType(filename string, head []byte) string {
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" {
+		if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+			contentType = byExt
+		}
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
+	}
+	return contentType
+}
+
+// _typeExtensions are preferred extensions of MIME types having several.
+var _typeExtensions = map[string]string{
+	"text/plain": ".txt",
+	"image/jpeg": ".jpg",
+	"audio/mpeg": ".mp3",
+}
+
+// attachmentFilename returns filename with extension of contentType. Static
+// files are served with type of their extension, so it must not differ from
+// checked type, e.g. html must not pass as plain text.
+func attachmentFilename(filename, contentType string) string {
+	ext := filepath.Ext(filename)
+	if extType, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil && extType == contentType {
+		return filename
+	}
+
+	newExt, ok := _typeExtensions[contentType]
+	if !ok {
+		newExt = ".bin"
+		if exts, err := mime.ExtensionsByType(contentType); err == nil && len(exts) > 0 {
+			newExt = exts[0]
+		}
+	}
+	return strings.TrimSuffix(filename, ext) + newExt
+}
+
+// createAttachmentFile creates new file in attachments directory, adding
+// number to filename if it is taken.
+func (app *App) createAttachmentFile(filename string) (*os.File, string, error) {
+	if err := os.MkdirAll(app.attachmentsDir(), 0o755); err != nil {
+		return nil, "", fmt.Errorf("create attachments directory: %w", err)
+	}
+
+	ext := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(filepath.Join(app.attachmentsDir(), filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			filename = fmt.Sprintf("%s-%d%s", stem, i, ext)
+			continue
+		} else if err != nil {
+			return nil, "", fmt.Errorf("create attachment file: %w", err)
+		}
+
+		return f, filename, nil
+	}
+}
+
+// CreateAttachment stores attachment with given filename, renaming it if
+// filename is taken already. Attachment must be of allowed type and not larger
+// than maximum size, extension is changed to one of its type if it differs.
+func (app *App) CreateAttachment(filename string, r io.Reader) (AttachmentModel, error) {
+	filename = sanitizeFilename(filename)
+
+	content, err := io.ReadAll(io.LimitReader(r, app.AttachmentMaxSize+1))
+	if err != nil {
+		return AttachmentModel{}, fmt.Errorf("read attachment: %w", err)
+	}
+
+	if int64(len(content)) > app.AttachmentMaxSize {
+		return AttachmentModel{}, ErrAttachmentTooLarge
+	}
+
+	contentType := detectContentType(filename, content)
+	if !allowedType(contentType, app.AttachmentTypes) {
+		return AttachmentModel{}, ErrAttachmentNotAllowed
+	}
+
+	f, filename, err := app.createAttachmentFile(attachmentFilename(filename, contentType))
+	if err != nil {
+		return AttachmentModel{}, err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(content); err != nil {
+		return AttachmentModel{}, fmt.Errorf("write attachment %q: %w", filename, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return AttachmentModel{}, fmt.Errorf("close attachment %q: %w", filename, err)
+	}
+
+	return app.newAttachmentModel(filename, int64(len(content)), contentType), nil
+}
+
+// GetAttachments returns page of at most limit attachments following cursor,
+// ordered by filename.
+func (app *App) GetAttachments(limit int, cursor string) (ListResponseModel[AttachmentModel], error) {
+	entries, err := os.ReadDir(app.attachmentsDir())
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return ListResponseModel[AttachmentModel]{}, fmt.Errorf("read attachments directory: %w", err)
+	}
+
+	res := []AttachmentModel{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return ListResponseModel[AttachmentModel]{}, fmt.Errorf("stat attachment %q: %w", entry.Name(), err)
+		}
+
+		contentType := mime.TypeByExtension(filepath.Ext(entry.Name()))
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			contentType = mediaType
+		}
+
+		res = append(res, app.newAttachmentModel(entry.Name(), info.Size(), contentType))
+	}
+
+	// directory entries are sorted by filename
+	return paginateList(res, func(attachment AttachmentModel) string {
+		return attachment.Filename
+	}, strings.Compare, limit, cursor)
+}
+
+// DeleteAttachment deletes attachment with given filename.
+func (app *App) DeleteAttachment(filename string) error {
+	if filename != sanitizeFilename(filename) {
+		return ErrAttachmentNotFound
+	}
+
+	err := os.Remove(filepath.Join(app.attachmentsDir(), filename))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrAttachmentNotFound
+	}
+	return err
+}
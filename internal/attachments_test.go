@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for filename, want := range map[string]string{
+		"image.png":           "image.png",
+		"../../etc/passwd":    "passwd",
+		`C:\Users\me\pic.jpg`: "pic.jpg",
+		".hidden":             "hidden",
+		"what?.png":           "what_.png",
+		"":                    "attachment",
+		"..":                  "attachment",
+		"картинка.webp":       "картинка.webp",
+	} {
+		assert.Equal(t, want, sanitizeFilename(filename), filename)
+	}
+}
+
+func TestAllowedType(t *testing.T) {
+	allowed := []string{"image/*", "application/pdf"}
+	assert.True(t, allowedType("image/png", allowed))
+	assert.True(t, allowedType("application/pdf", allowed))
+	assert.False(t, allowedType("text/html", allowed))
+	assert.False(t, allowedType("imagex/png", allowed))
+}
+
+func TestAttachmentFilename(t *testing.T) {
+	for _, test := range []struct {
+		filename, content, want string
+	}{
+		{"image.png", "\x89PNG\r\n\x1a\n", "image.png"},
+		{"photo.jpeg", "\xff\xd8\xff", "photo.jpeg"},
+		{"image.jpg", "\x89PNG\r\n\x1a\n", "image.png"},
+		{"doc.pdf", "%PDF-1.4", "doc.pdf"},
+		{"notes.txt", "plain text", "notes.txt"},
+		// active content sniffed as plain text must not be served as such
+		{"x.html", "<svg onload=alert(1)>", "x.txt"},
+		{"x.svg", "<svg onload=alert(1)>", "x.txt"},
+		{"noext", "plain text", "noext.txt"},
+	} {
+		contentType := detectContentType(test.filename, []byte(test.content))
+		assert.Equal(t, test.want, attachmentFilename(test.filename, contentType), test.filename)
+	}
+}
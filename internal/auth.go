@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -25,7 +26,23 @@ func CreateAccessToken(config Config, username string) (string, error) {
 	}).SignedString([]byte(config.SessionKey)) //(to_encode, config.session_key, JWT_ALGORITHM)
 }
 
-func ValidateToken(config Config, token string /*= Depends(oauth2_scheme)*/) error {
+type authorKey struct{}
+
+// WithAuthor returns context of request made by authenticated user.
+func WithAuthor(ctx context.Context, username string) context.Context {
+	return context.WithValue(ctx, authorKey{}, username)
+}
+
+// authorFrom returns name of user making request with ctx.
+func authorFrom(ctx context.Context) string {
+	if username, ok := ctx.Value(authorKey{}).(string); ok && username != "" {
+		return username
+	}
+	return _gitDefaultAuthor
+}
+
+// ValidateToken checks token and returns name of user it was issued to.
+func ValidateToken(config Config, token string /*= Depends(oauth2_scheme)*/) (string, error) {
 	// try:
 	var claims claims
 	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
@@ -36,15 +53,15 @@ func ValidateToken(config Config, token string /*= Depends(oauth2_scheme)*/) err
 		return []byte(config.SessionKey), nil
 	})
 	if err != nil {
-		return fmt.Errorf("parse token: %w", err)
+		return "", fmt.Errorf("parse token: %w", err)
 	}
 
 	username, err := claims.GetSubject()
 	if err != nil || !strings.EqualFold(username, config.Username) {
-		return fmt.Errorf("ValueError")
+		return "", fmt.Errorf("ValueError")
 	}
 
-	return nil
+	return username, nil
 	// except (JWTError, ValueError):
 	//     raise HTTPException(
 	//         status_code=401,
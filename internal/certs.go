@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Certificate is TLS certificate loaded from files, which might be reloaded
+// when they change, e.g. when certificate is renewed.
+type Certificate struct {
+	certFile, keyFile string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+// LoadCertificate loads PEM encoded certificate and its key.
+func LoadCertificate(certFile, keyFile string) (*Certificate, error) {
+	res := &Certificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := res.reload(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (c *Certificate) reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load certificate %q, key %q: %w", c.certFile, c.keyFile, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.cert = &cert
+	return nil
+}
+
+// GetCertificate returns current certificate, it is used as
+// tls.Config.GetCertificate.
+func (c *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.cert, nil
+}
+
+// Watch reloads certificate when its files change until ctx is done. If new
+// files are invalid, e.g. only one of them is written yet, previous
+// certificate is kept until next change.
+func (c *Certificate) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("watch certificate: %w", err)
+	}
+	defer watcher.Close()
+
+	// directories are watched, as files are often replaced by renames or
+	// symlink swaps, which are not seen by watches on files themselves
+	for _, dir := range []string{filepath.Dir(c.certFile), filepath.Dir(c.keyFile)} {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("watch %q: %w", dir, err)
+		}
+	}
+
+	files := Set[string]{
+		filepath.Clean(c.certFile): {},
+		filepath.Clean(c.keyFile):  {},
+	}
+
+	debounce := time.NewTimer(_watchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-watcher.Events:
+			if event.Has(fsnotify.Chmod) {
+				continue
+			}
+
+			// symlinks to files might be swapped by changing link to data
+			// directory, e.g. "..data" in kubernetes secrets
+			if !files.Has(filepath.Clean(event.Name)) && !strings.HasPrefix(filepath.Base(event.Name), "..") {
+				continue
+			}
+
+			debounce.Reset(_watchDebounce)
+		case err := <-watcher.Errors:
+			log.Println("watch certificate:", err.Error())
+			debounce.Reset(_watchDebounce)
+		case <-debounce.C:
+			if err := c.reload(); err != nil {
+				log.Println("reload certificate:", err.Error())
+				continue
+			}
+
+			log.Println("certificate reloaded")
+		}
+	}
+}
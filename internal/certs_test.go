@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCertificate writes self-signed certificate for host and its key.
+func writeCertificate(t *testing.T, certFile, keyFile, host string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644))
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, certFile, keyFile, "old.example.com")
+
+	cert, err := LoadCertificate(certFile, keyFile)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		assert.NoError(t, cert.Watch(ctx))
+	}()
+
+	host := func() string {
+		current, err := cert.GetCertificate(nil)
+		require.NoError(t, err)
+
+		leaf, err := x509.ParseCertificate(current.Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+	assert.Equal(t, "old.example.com", host())
+
+	// watch is set up asynchronously, so files are rewritten until noticed,
+	// less often than changes are debounced
+	assert.Eventually(t, func() bool {
+		writeCertificate(t, certFile, keyFile, "new.example.com")
+		return host() == "new.example.com"
+	}, 5*time.Second, 4*_watchDebounce)
+
+	cancel()
+	<-watching
+}
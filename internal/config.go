@@ -2,9 +2,13 @@ package internal
 
 import (
 	"log"
+	"net"
 	"os"
+	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // from base64 import b32encode
@@ -38,14 +42,73 @@ func get_env(key string, mandatory bool, defaultT any, cast_int bool) any { // s
 	return value
 }
 
+// Get a boolean environment variable.
+func get_bool_env(key string, defaultT bool) bool {
+	value := get_env(key, false, strconv.FormatBool(defaultT), false).(string)
+	res, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("Invalid value %q for %s.", value, key)
+	}
+	return res
+}
+
+// Get a comma separated list environment variable.
+func get_list_env(key string, defaultT []string) []string {
+	value, ok := get_env(key, false, nil, false).(string)
+	if !ok {
+		return defaultT
+	}
+
+	res := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
 type Config struct {
 	DataPath          string
+	IndexPath         string
 	AuthType          AuthType
 	Username          string
 	Password          string
 	SessionKey        string
 	SessionExpiryDays int // TODO: time.Duration
 	TotpKey           string
+	// Period of full notes directory reconcile with index, 0 to disable
+	ReconcileInterval time.Duration
+	// Whether notes are searched in subdirectories of DataPath
+	Recursive bool
+	// Maximum number of previous versions kept per note, 0 for unlimited
+	HistoryMaxVersions int
+	// Maximum age of previous versions of notes, 0 for unlimited
+	HistoryMaxAge time.Duration
+	// Whether changes of notes are committed to git repository in DataPath
+	Git bool
+	// Time to wait for more note updates before committing them together
+	GitCommitDelay time.Duration
+	// Time deleted notes are kept in trash, 0 to keep forever
+	TrashRetention time.Duration
+	// Maximum size of uploaded attachment in bytes
+	AttachmentMaxSize int64
+	// MIME types of attachments allowed to upload, e.g. "image/*"
+	AttachmentTypes []string
+	// Whether inline base64 images are moved to attachments on save
+	ExtractImages bool
+	// YAML file with webhooks to deliver note changes to, empty to disable
+	WebhooksPath string
+	// TCP address to listen on, as host:port
+	ListenAddress string
+	// Unix socket to listen on instead of ListenAddress, empty to disable
+	SocketPath string
+	// URL path prefix flatnotes is served under, e.g. "/notes", empty if it is
+	// served at root
+	PathPrefix string
+	// PEM encoded TLS certificate and key, TLS is disabled if empty. Files
+	// are reloaded when they change.
+	TLSCertFile, TLSKeyFile string
 }
 
 func get_auth_type() AuthType {
@@ -74,16 +137,62 @@ func get_totp_key(auth_type AuthType) string {
 	return totp_key
 }
 
+func get_path_prefix() string {
+	const key = "FLATNOTES_PATH_PREFIX"
+	raw := get_env(key, false, "", false).(string)
+	prefix := strings.Trim(raw, "/")
+	if prefix == "" {
+		return ""
+	}
+
+	if strings.ContainsAny(prefix, "?#") || path.Clean("/"+prefix) != "/"+prefix {
+		log.Fatalf("Invalid value %q for %s. Must be URL path like /notes.", raw, key)
+	}
+	return "/" + prefix
+}
+
+func get_tls_files() (string, string) {
+	cert_file := get_env("FLATNOTES_TLS_CERT_FILE", false, "", false).(string)
+	key_file := get_env("FLATNOTES_TLS_KEY_FILE", cert_file != "", "", false).(string)
+	if cert_file == "" && key_file != "" {
+		log.Fatalf("Environment variable FLATNOTES_TLS_CERT_FILE must be set.")
+	}
+	return cert_file, key_file
+}
+
 func NewConfig() Config {
 	auth_type := get_auth_type()
 	auth_needed := auth_type != AuthTypeNone && auth_type != AuthTypeReadOnly
+	data_path := get_env("FLATNOTES_PATH", false, "/data", false).(string)
+	listen_address := net.JoinHostPort(
+		get_env("FLATNOTES_HOST", false, "", false).(string),
+		strconv.Itoa(get_env("FLATNOTES_PORT", false, 8080, true).(int)),
+	)
+	tls_cert_file, tls_key_file := get_tls_files()
 	return Config{
-		DataPath:          get_env("FLATNOTES_PATH", false, "/data", false).(string),
-		AuthType:          auth_type,
-		Username:          get_env("FLATNOTES_USERNAME", auth_needed, Optional[string]{}, false).(string),
-		Password:          get_env("FLATNOTES_PASSWORD", auth_needed, Optional[string]{}, false).(string),
-		SessionKey:        get_env("FLATNOTES_SECRET_KEY", auth_needed, Optional[string]{}, false).(string),
-		SessionExpiryDays: get_env("FLATNOTES_SESSION_EXPIRY_DAYS", false, 30, true).(int),
-		TotpKey:           get_totp_key(auth_type),
+		DataPath:           data_path,
+		IndexPath:          get_env("FLATNOTES_INDEX_PATH", false, filepath.Join(data_path, ".flatnotes"), false).(string),
+		AuthType:           auth_type,
+		Username:           get_env("FLATNOTES_USERNAME", auth_needed, Optional[string]{}, false).(string),
+		Password:           get_env("FLATNOTES_PASSWORD", auth_needed, Optional[string]{}, false).(string),
+		SessionKey:         get_env("FLATNOTES_SECRET_KEY", auth_needed, Optional[string]{}, false).(string),
+		SessionExpiryDays:  get_env("FLATNOTES_SESSION_EXPIRY_DAYS", false, 30, true).(int),
+		TotpKey:            get_totp_key(auth_type),
+		ReconcileInterval:  time.Duration(get_env("FLATNOTES_RECONCILE_INTERVAL_MINUTES", false, 10, true).(int)) * time.Minute,
+		Recursive:          get_bool_env("FLATNOTES_RECURSIVE", false),
+		HistoryMaxVersions: get_env("FLATNOTES_HISTORY_MAX_VERSIONS", false, 100, true).(int),
+		HistoryMaxAge:      time.Duration(get_env("FLATNOTES_HISTORY_MAX_AGE_DAYS", false, 0, true).(int)) * 24 * time.Hour,
+		Git:                get_bool_env("FLATNOTES_GIT", false),
+		GitCommitDelay:     time.Duration(get_env("FLATNOTES_GIT_COMMIT_DELAY_SECONDS", false, 10, true).(int)) * time.Second,
+		TrashRetention:     time.Duration(get_env("FLATNOTES_TRASH_RETENTION_DAYS", false, 30, true).(int)) * 24 * time.Hour,
+		AttachmentMaxSize:  int64(get_env("FLATNOTES_ATTACHMENT_MAX_SIZE_MB", false, 10, true).(int)) << 20,
+		AttachmentTypes:    get_list_env("FLATNOTES_ATTACHMENT_TYPES", []string{"image/*", "application/pdf", "text/plain"}),
+		ExtractImages:      get_bool_env("FLATNOTES_EXTRACT_IMAGES", false),
+		WebhooksPath:       get_env("FLATNOTES_WEBHOOKS_FILE", false, "", false).(string),
+		ListenAddress:      listen_address,
+		SocketPath:         get_env("FLATNOTES_SOCKET", false, "", false).(string),
+		PathPrefix:         get_path_prefix(),
+		TLSCertFile:        tls_cert_file,
+		TLSKeyFile:         tls_key_file,
 	}
 }
@@ -0,0 +1,218 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type diffOp byte
+
+const (
+	diffEqual  diffOp = ' '
+	diffDelete diffOp = '-'
+	diffInsert diffOp = '+'
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// _diffMaxEdits is maximum number of deleted and inserted lines searched for
+// by diffLines, bigger changes are shown as replacement of whole changed part,
+// so that time and memory are bounded.
+const _diffMaxEdits = 1000
+
+// diffLines computes shortest edit script turning a into b using Myers
+// algorithm.
+func diffLines(a, b []string) []diffLine {
+	// common prefix and suffix are not searched for edits
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	res := []diffLine{}
+	for _, line := range a[:prefix] {
+		res = append(res, diffLine{diffEqual, line})
+	}
+	res = append(res, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		res = append(res, diffLine{diffEqual, line})
+	}
+	return res
+}
+
+// myersDiff computes shortest edit script turning a into b, if it has at most
+// _diffMaxEdits edits, otherwise all of a is deleted and all of b inserted.
+func myersDiff(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	maxD := min(n+m, _diffMaxEdits)
+	offset := maxD + 1
+	// k diagonal -> furthest x reached on it
+	v := make([]int, 2*offset+1)
+	// diagonals -d..d of v before each round d, needed to backtrack the path
+	trace := [][]int{}
+	found := false
+SEARCH:
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				found = true
+				break SEARCH
+			}
+		}
+	}
+
+	res := []diffLine{}
+	if !found {
+		for _, line := range a {
+			res = append(res, diffLine{diffDelete, line})
+		}
+		for _, line := range b {
+			res = append(res, diffLine{diffInsert, line})
+		}
+		return res
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		// path starts at 0,0
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prevK := k - 1
+			if k == -d || k != d && v[d+k-1] < v[d+k+1] {
+				prevK = k + 1
+			}
+			prevX = v[d+prevK]
+			prevY = prevX - prevK
+		}
+
+		for x > prevX && y > prevY {
+			res = append(res, diffLine{diffEqual, a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				res = append(res, diffLine{diffInsert, b[y-1]})
+			} else {
+				res = append(res, diffLine{diffDelete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	slices.Reverse(res)
+	return res
+}
+
+// splitLines splits text into lines keeping line endings.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// _diffContext is number of unchanged lines shown around changes.
+const _diffContext = 3
+
+// unifiedDiff returns differences between texts a and b in unified format,
+// empty string if texts are equal.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	script := diffLines(splitLines(a), splitLines(b))
+
+	// number of lines of a and b before script line
+	linesA, linesB := make([]int, len(script)+1), make([]int, len(script)+1)
+	for i, line := range script {
+		linesA[i+1], linesB[i+1] = linesA[i], linesB[i]
+		if line.op != diffInsert {
+			linesA[i+1]++
+		}
+		if line.op != diffDelete {
+			linesB[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(script); {
+		for i < len(script) && script[i].op == diffEqual {
+			i++
+		}
+		if i == len(script) {
+			break
+		}
+
+		// hunk spans changes separated by at most two contexts
+		start, end := max(i-_diffContext, 0), i
+		for end < len(script) {
+			if script[end].op != diffEqual {
+				end++
+				continue
+			}
+
+			j := end
+			for j < len(script) && script[j].op == diffEqual {
+				j++
+			}
+			if j == len(script) || j-end > 2*_diffContext {
+				end = min(end+_diffContext, len(script))
+				break
+			}
+			end = j
+		}
+
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+		}
+
+		hunkRange := func(from, count int) string {
+			if count == 0 {
+				return fmt.Sprintf("%d,0", from)
+			}
+			if count == 1 {
+				return fmt.Sprint(from + 1)
+			}
+			return fmt.Sprintf("%d,%d", from+1, count)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n",
+			hunkRange(linesA[start], linesA[end]-linesA[start]),
+			hunkRange(linesB[start], linesB[end]-linesB[start]),
+		)
+		for _, line := range script[start:end] {
+			sb.WriteByte(byte(line.op))
+			sb.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+	return sb.String()
+}
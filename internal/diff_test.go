@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	assert.Equal(t, `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -12,4 +12,5 @@
+ 12
+ 13
+ 14
+-15
+\ No newline at end of file
++15
++16
+`, unifiedDiff("a", "b", a, b))
+
+	assert.Equal(t, "", unifiedDiff("a", "b", a, a))
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n", unifiedDiff("a", "b", "", "new\n"))
+}
+
+func TestDiffLines(t *testing.T) {
+	lines := func(prefix string, n int) []string {
+		res := make([]string, n)
+		for i := range res {
+			res[i] = fmt.Sprint(prefix, i%7, "\n")
+		}
+		return res
+	}
+
+	for name, test := range map[string]struct {
+		a, b  []string
+		edits int
+	}{
+		"small":       {a: lines("a", 20), b: append(lines("a", 10), lines("b", 5)...), edits: 15},
+		"over limit":  {a: lines("a", 5000), b: lines("b", 5000), edits: 10000},
+		"same edges":  {a: append(append(lines("a", 3000), "x\n"), lines("a", 3000)...), b: append(append(lines("a", 3000), "y\n"), lines("a", 3000)...), edits: 2},
+		"empty":       {a: nil, b: nil, edits: 0},
+		"insert only": {a: nil, b: lines("b", 3), edits: 3},
+	} {
+		script := diffLines(test.a, test.b)
+
+		// script turns a into b
+		gotA, gotB, edits := []string{}, []string{}, 0
+		for _, line := range script {
+			if line.op != diffInsert {
+				gotA = append(gotA, line.text)
+			}
+			if line.op != diffDelete {
+				gotB = append(gotB, line.text)
+			}
+			if line.op != diffEqual {
+				edits++
+			}
+		}
+		assert.Equal(t, append([]string{}, test.a...), gotA, name)
+		assert.Equal(t, append([]string{}, test.b...), gotB, name)
+		assert.Equal(t, test.edits, edits, name)
+	}
+}
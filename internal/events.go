@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+type EventType string
+
+const (
+	EventCreated EventType = "created"
+	EventUpdated EventType = "updated"
+	EventRenamed EventType = "renamed"
+	EventDeleted EventType = "deleted"
+	// EventReset is sent when requested events are not kept anymore, so
+	// client must reload everything.
+	EventReset EventType = "reset"
+)
+
+// Event is a change of note.
+type Event struct {
+	ID    uint64    `json:"id"`
+	Type  EventType `json:"type"`
+	Title string    `json:"title,omitempty"`
+	// Previous title of renamed note
+	OldTitle     string `json:"oldTitle,omitempty"`
+	LastModified int64  `json:"lastModified,omitempty"`
+	// Version of note content, as in ETag header
+	ETag string `json:"etag,omitempty"`
+	// Tags of note, for deleted note tags it had
+	Tags []string `json:"tags,omitempty"`
+}
+
+// newEvent returns event of note change.
+func newEvent(typ EventType, doc NoteDocument) Event {
+	tags := lo.Keys(doc.Tags)
+	slices.Sort(tags)
+
+	return Event{
+		Type:         typ,
+		Title:        doc.Title,
+		LastModified: doc.Modtime.Unix(),
+		ETag:         contentETag([]byte(doc.Content)),
+		Tags:         tags,
+	}
+}
+
+const (
+	// _eventsBufferSize is number of last events kept to be resent to
+	// reconnecting clients.
+	_eventsBufferSize = 1000
+	// _eventsSubscriberBuffer is number of events subscriber might fall
+	// behind before it is dropped.
+	_eventsSubscriberBuffer = 100
+)
+
+// Events delivers changes of notes to subscribers. Last events are kept, so
+// subscriber might resume from last event it got.
+type Events struct {
+	mu sync.Mutex
+	// last events, oldest first
+	buffer []Event
+	// id of next event, ids start from current time so ids from before
+	// restart are never taken for recent ones
+	nextID      uint64
+	subscribers Set[chan Event]
+	closed      bool
+}
+
+func newEvents() *Events {
+	return &Events{
+		nextID:      uint64(time.Now().UnixNano()),
+		subscribers: Set[chan Event]{},
+	}
+}
+
+// Publish assigns id to event and sends it to subscribers. Subscribers which
+// are too slow to receive events are dropped.
+func (e *Events) Publish(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	event.ID = e.nextID
+	e.nextID++
+
+	e.buffer = append(e.buffer, event)
+	if len(e.buffer) > _eventsBufferSize {
+		e.buffer = slices.Clone(e.buffer[len(e.buffer)-_eventsBufferSize:])
+	}
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// missed returns events after one with lastEventID, must be called with mu
+// locked. If they are not kept anymore, reset event is returned.
+func (e *Events) missed(lastEventID string) []Event {
+	if lastEventID == "" {
+		return nil
+	}
+
+	reset := []Event{{
+		ID:   e.nextID - 1,
+		Type: EventReset,
+	}}
+
+	id, err := strconv.ParseUint(lastEventID, 10, 64)
+	if err != nil || id >= e.nextID {
+		return reset
+	}
+
+	if len(e.buffer) == 0 || id < e.buffer[0].ID-1 {
+		if id == e.nextID-1 {
+			return nil
+		}
+		return reset
+	}
+
+	i, _ := slices.BinarySearchFunc(e.buffer, id+1, func(event Event, id uint64) int {
+		return cmp.Compare(event.ID, id)
+	})
+	return slices.Clone(e.buffer[i:])
+}
+
+// Subscribe returns events missed since lastEventID, if it is not empty, and
+// channel of new events. Channel is closed if subscriber falls behind or
+// events are closed. Unsubscribe must be called when events are not needed
+// anymore.
+func (e *Events) Subscribe(lastEventID string) (missed []Event, events <-chan Event, unsubscribe func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ch := make(chan Event, _eventsSubscriberBuffer)
+	if e.closed {
+		close(ch)
+		return nil, ch, func() {}
+	}
+
+	e.subscribers[ch] = struct{}{}
+	return e.missed(lastEventID), ch, func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		if e.subscribers.Has(ch) {
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close closes channels of all subscribers, so they stop waiting for events.
+func (e *Events) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	for ch := range e.subscribers {
+		close(ch)
+	}
+	clear(e.subscribers)
+}
+
+// publish publishes event, if events are enabled.
+func (app *App) publish(event Event) {
+	if app.Events == nil {
+		return
+	}
+
+	app.Events.Publish(event)
+}
@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventsResume(t *testing.T) {
+	e := newEvents()
+	e.Publish(Event{Type: EventCreated, Title: "a"})
+	e.Publish(Event{Type: EventUpdated, Title: "a"})
+	e.Publish(Event{Type: EventDeleted, Title: "a"})
+	first := e.buffer[0].ID
+
+	missed, _, unsubscribe := e.Subscribe(strconv.FormatUint(first, 10))
+	defer unsubscribe()
+	assert.Equal(t, []EventType{EventUpdated, EventDeleted}, lo.Map(missed, func(event Event, _ int) EventType {
+		return event.Type
+	}))
+
+	missed, _, unsubscribe = e.Subscribe(strconv.FormatUint(first+2, 10))
+	defer unsubscribe()
+	assert.Empty(t, missed)
+
+	// events from before restart are lost
+	missed, _, unsubscribe = e.Subscribe("1")
+	defer unsubscribe()
+	assert.Equal(t, []Event{{ID: first + 2, Type: EventReset}}, missed)
+}
+
+func TestEventsSlowSubscriber(t *testing.T) {
+	e := newEvents()
+	_, events, unsubscribe := e.Subscribe("")
+	defer unsubscribe()
+
+	for i := 0; i <= _eventsSubscriberBuffer; i++ {
+		e.Publish(Event{Type: EventUpdated, Title: "a"})
+	}
+
+	count := 0
+	for range events {
+		count++
+	}
+	assert.Equal(t, _eventsSubscriberBuffer, count)
+}
+
+func TestEventsPublished(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+
+	_, events, unsubscribe := app.Events.Subscribe("")
+	defer unsubscribe()
+
+	created, err := app.CreateNote(ctx, NotePostModel{Title: "a", Content: "a"})
+	require.NoError(t, err)
+	_, err = app.UpdateNote(ctx, "a", NotePatchModel{NewTitle: lo.ToPtr("b")}, "")
+	require.NoError(t, err)
+	require.NoError(t, app.DeleteNote(ctx, "b", ""))
+	// already indexed note is not reported again
+	require.NoError(t, app.syncNote("b"))
+
+	expected := []Event{
+		{Type: EventCreated, Title: "a", ETag: created.ETag},
+		{Type: EventRenamed, Title: "b", OldTitle: "a", ETag: created.ETag},
+		{Type: EventDeleted, Title: "b"},
+	}
+	for _, want := range expected {
+		got := <-events
+		assert.Equal(t, want.Type, got.Type)
+		assert.Equal(t, want.Title, got.Title)
+		assert.Equal(t, want.OldTitle, got.OldTitle)
+		assert.Equal(t, want.ETag, got.ETag)
+	}
+	assert.Empty(t, events)
+}
@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NoteMetadata is structured data from note front matter.
+type NoteMetadata struct {
+	Aliases []string   `json:"aliases,omitempty"`
+	Created *time.Time `json:"created,omitempty"`
+	// All other front matter keys
+	Extra map[string]any `json:"extra,omitempty"`
+}
+
+// splitFrontMatter splits content into YAML front matter block, delimited by
+// "---" lines at the very beginning of content, and the rest of content.
+func splitFrontMatter(content string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(content, "---\n")
+	if !ok {
+		if rest, ok = strings.CutPrefix(content, "---\r\n"); !ok {
+			return "", content, false
+		}
+	}
+
+	for i := 0; i <= len(rest); {
+		j := strings.IndexByte(rest[i:], '\n')
+		if j == -1 {
+			j = len(rest) - i
+		}
+
+		line := strings.TrimRight(rest[i:i+j], "\r")
+		if line == "---" || line == "..." {
+			body := rest[min(i+j+1, len(rest)):]
+			return rest[:i], body, true
+		}
+		i += j + 1
+	}
+	return "", content, false
+}
+
+// parseFrontMatter parses front matter of content, returning metadata, tags
+// declared in front matter and content without front matter. Content having
+// no or invalid front matter is returned as is.
+func parseFrontMatter(content string) (NoteMetadata, Set[string], string) {
+	frontMatter, body, ok := splitFrontMatter(content)
+	if !ok {
+		return NoteMetadata{}, Set[string]{}, content
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal([]byte(frontMatter), &values); err != nil {
+		return NoteMetadata{}, Set[string]{}, content
+	}
+
+	metadata := NoteMetadata{}
+	tags := Set[string]{}
+	for key, value := range values {
+		switch strings.ToLower(key) {
+		case "tags", "tag":
+			// tags might be written as "tags: a, b" or "tags: a b"
+			if s, ok := value.(string); ok {
+				value = strings.FieldsFunc(s, func(r rune) bool {
+					return r == ',' || r == ' '
+				})
+			}
+			for _, tag := range yamlStrings(value) {
+				tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+				if tag != "" {
+					tags[tag] = struct{}{}
+				}
+			}
+		case "aliases", "alias":
+			metadata.Aliases = append(metadata.Aliases, yamlStrings(value)...)
+		case "created":
+			if created, ok := yamlTime(value); ok {
+				metadata.Created = &created
+				continue
+			}
+			fallthrough
+		default:
+			if metadata.Extra == nil {
+				metadata.Extra = map[string]any{}
+			}
+			metadata.Extra[key] = normalizeYAML(value)
+		}
+	}
+	return metadata, tags, body
+}
+
+// yamlStrings converts YAML list or scalar to strings.
+func yamlStrings(value any) []string {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []string:
+		return value
+	case []any:
+		res := []string{}
+		for _, item := range value {
+			res = append(res, yamlText(item))
+		}
+		return res
+	default:
+		return []string{yamlText(value)}
+	}
+}
+
+func yamlTime(value any) (time.Time, bool) {
+	switch value := value.(type) {
+	case time.Time:
+		return value, true
+	case string:
+		for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// normalizeYAML converts maps with non string keys, which cannot be encoded
+// to JSON, to string keyed ones.
+func normalizeYAML(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		for k, v := range value {
+			value[k] = normalizeYAML(v)
+		}
+		return value
+	case map[any]any:
+		res := make(map[string]any, len(value))
+		for k, v := range value {
+			res[fmt.Sprint(k)] = normalizeYAML(v)
+		}
+		return res
+	case []any:
+		for i, v := range value {
+			value[i] = normalizeYAML(v)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// yamlText flattens YAML value into text for indexing.
+func yamlText(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case time.Time:
+		if value.Equal(value.Truncate(24 * time.Hour)) {
+			return value.Format(time.DateOnly)
+		}
+		return value.Format(time.RFC3339)
+	case []any:
+		res := make([]string, len(value))
+		for i, item := range value {
+			res[i] = yamlText(item)
+		}
+		return strings.Join(res, " ")
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		res := make([]string, 0, len(value))
+		for _, k := range keys {
+			res = append(res, k+" "+yamlText(value[k]))
+		}
+		return strings.Join(res, " ")
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// searchableFields flattens metadata into texts indexed by lowercase key.
+func (m NoteMetadata) searchableFields() map[string]string {
+	res := map[string]string{}
+	for key, value := range m.Extra {
+		res[strings.ToLower(key)] = yamlText(normalizeYAML(value))
+	}
+	if m.Created != nil {
+		res["created"] = yamlText(*m.Created)
+	}
+	return res
+}
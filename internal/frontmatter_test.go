@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	content := "---\ntags: [Work, '#plans']\naliases: Roadmap\ncreated: 2024-01-02\nauthor: bob\n---\nbody #inline\n"
+	metadata, tags, body := parseFrontMatter(content)
+	assert.Equal(t, Set[string]{"work": {}, "plans": {}}, tags)
+	assert.Equal(t, "body #inline\n", body)
+	assert.Equal(t, []string{"Roadmap"}, metadata.Aliases)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), *metadata.Created)
+	assert.Equal(t, map[string]any{"author": "bob"}, metadata.Extra)
+	assert.Equal(t, map[string]string{"author": "bob", "created": "2024-01-02"}, metadata.searchableFields())
+
+	metadata, tags, _ = parseFrontMatter("---\ntags: work, plans\naliases: My Project\n---\n")
+	assert.Equal(t, Set[string]{"work": {}, "plans": {}}, tags)
+	assert.Equal(t, []string{"My Project"}, metadata.Aliases)
+
+	metadata, _, _ = parseFrontMatter("---\naliases: [My Project, mp]\n---\n")
+	assert.Equal(t, []string{"My Project", "mp"}, metadata.Aliases)
+
+	for _, content := range []string{
+		"no front matter",
+		"---\nunterminated: true\n",
+		"---\n: invalid: yaml\n---\nbody",
+		"text\n---\nkey: value\n---\n",
+	} {
+		metadata, tags, body := parseFrontMatter(content)
+		assert.Equal(t, NoteMetadata{}, metadata, content)
+		assert.Empty(t, tags, content)
+		assert.Equal(t, content, body, content)
+	}
+}
@@ -1,6 +1,8 @@
 package fts
 
 import (
+	"maps"
+	"math"
 	"sync"
 
 	"github.com/samber/lo"
@@ -9,7 +11,8 @@ import (
 type DocumentField struct {
 	Content string
 	Terms   []string
-	Weight  float64
+	// Weight is the default BM25F boost of the field.
+	Weight float64
 }
 
 type Document interface {
@@ -17,119 +20,428 @@ type Document interface {
 	Fields() map[string]DocumentField
 }
 
+type params struct {
+	k1     float64
+	b      float64
+	boosts map[string]float64
+}
+
+type Option func(*params)
+
+// WithK1 sets the BM25 term frequency saturation parameter.
+func WithK1(k1 float64) Option {
+	return func(p *params) {
+		p.k1 = k1
+	}
+}
+
+// WithB sets the BM25 document length normalization parameter.
+func WithB(b float64) Option {
+	return func(p *params) {
+		p.b = b
+	}
+}
+
+// WithFieldBoost overrides the boost declared by the field Weight.
+func WithFieldBoost(field string, boost float64) Option {
+	return func(p *params) {
+		p.boosts[field] = boost
+	}
+}
+
 // Index is an inverted Index. It maps tokens to document IDs.
 type Index[D Document] struct {
-	mu sync.RWMutex
-	// Field -> Term -> Document ID -> Term count in document field
-	InvIndex map[string]map[string]map[string]int
-	// all Documents
-	Documents map[string]D
-	// Field -> Term -> Term frequency among all documents field
-	TermFreq map[string]map[string]int
-}
-
-func NewIndex[D Document]() *Index[D] {
-	InvIndex := map[string]map[string]map[string]int{}
-	TermFreq := map[string]map[string]int{}
-	for field := range func() D {
+	mu     sync.RWMutex
+	params params
+	// Field -> Term -> Document ID -> Term positions in document field
+	InvIndex map[string]map[string]map[string][]int
+	// all documents
+	documents map[string]D
+	// Field -> Document ID -> Number of terms in document field
+	FieldLen map[string]map[string]int
+	// Field -> Sum of FieldLen over documents, kept to get average in O(1)
+	fieldTotal map[string]int
+	// Field -> Field weight declared by documents
+	Weights map[string]float64
+}
+
+func NewIndex[D Document](opts ...Option) *Index[D] {
+	params := params{
+		k1:     1.2,
+		b:      0.75,
+		boosts: map[string]float64{},
+	}
+	for _, opt := range opts {
+		opt(&params)
+	}
+
+	idx := &Index[D]{
+		mu:         sync.RWMutex{},
+		params:     params,
+		InvIndex:   map[string]map[string]map[string][]int{},
+		documents:  map[string]D{},
+		FieldLen:   map[string]map[string]int{},
+		fieldTotal: map[string]int{},
+		Weights:    map[string]float64{},
+	}
+	for fieldName, field := range func() D {
 		var d D
 		return d
 	}().Fields() {
-		if _, ok := InvIndex[field]; !ok {
-			InvIndex[field] = map[string]map[string]int{}
-		}
+		idx.addField(fieldName, field.Weight)
+	}
+	return idx
+}
 
-		if _, ok := TermFreq[field]; !ok {
-			TermFreq[field] = map[string]int{}
-		}
+func (idx *Index[D]) addField(field string, weight float64) {
+	if _, ok := idx.InvIndex[field]; !ok {
+		idx.InvIndex[field] = map[string]map[string][]int{}
 	}
 
-	return &Index[D]{
-		mu:        sync.RWMutex{},
-		InvIndex:  InvIndex,
-		Documents: map[string]D{},
-		TermFreq:  TermFreq,
+	if _, ok := idx.FieldLen[field]; !ok {
+		idx.FieldLen[field] = map[string]int{}
 	}
+
+	idx.Weights[field] = weight
 }
 
-func (idx *Index[D]) add(field, term, docID string, cnt int) {
+// addTerm adds next term of document field to the index.
+func (idx *Index[D]) addTerm(field, term, docID string) {
 	if _, ok := idx.InvIndex[field][term]; !ok {
-		idx.InvIndex[field][term] = map[string]int{}
+		idx.InvIndex[field][term] = map[string][]int{}
 	}
 
-	idx.InvIndex[field][term][docID] += cnt
-	idx.TermFreq[field][term] += cnt
+	idx.InvIndex[field][term][docID] = append(idx.InvIndex[field][term][docID], idx.FieldLen[field][docID])
+	idx.FieldLen[field][docID]++
+	idx.fieldTotal[field]++
 }
 
-// add adds documents to the index.
+// Add adds documents to the index, replacing already indexed ones with same ID.
 func (idx *Index[D]) Add(docs ...D) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
 	for _, doc := range docs {
-		for fieldName, field := range doc.Fields() {
-			analyze(field.Content)(func(term Term) bool {
-				idx.add(fieldName, term.Term, doc.ID(), 1)
-				return true
-			})
-			for _, term := range field.Terms {
-				idx.add(fieldName, term, doc.ID(), 1)
+		idx.add(Analyze(doc))
+	}
+}
+
+// Analyzed is document with its fields split into terms, so that documents
+// might be tokenized concurrently, outside of index lock.
+type Analyzed[D Document] struct {
+	Doc D
+	// Field -> Terms of document field in order
+	terms map[string][]string
+	// Field -> Field weight declared by document
+	weights map[string]float64
+}
+
+// Analyze splits document fields into terms.
+func Analyze[D Document](doc D) Analyzed[D] {
+	res := Analyzed[D]{
+		Doc:     doc,
+		terms:   map[string][]string{},
+		weights: map[string]float64{},
+	}
+	for fieldName, field := range doc.Fields() {
+		res.weights[fieldName] = field.Weight
+
+		res.terms[fieldName] = append(Terms(field.Content), field.Terms...)
+	}
+	return res
+}
+
+// add adds document to the index, replacing already indexed one with same ID.
+func (idx *Index[D]) add(doc Analyzed[D]) {
+	id := doc.Doc.ID()
+	if _, ok := idx.documents[id]; ok {
+		idx.remove(id)
+	}
+
+	for fieldName, terms := range doc.terms {
+		idx.addField(fieldName, doc.weights[fieldName])
+		for _, term := range terms {
+			idx.addTerm(fieldName, term, id)
+		}
+	}
+	idx.documents[id] = doc.Doc
+}
+
+func (idx *Index[D]) remove(id string) {
+	for field, terms := range idx.InvIndex {
+		for term, docs := range terms {
+			delete(docs, id)
+			if len(docs) == 0 {
+				delete(terms, term)
 			}
 		}
-		idx.Documents[doc.ID()] = doc
+		idx.fieldTotal[field] -= idx.FieldLen[field][id]
+		delete(idx.FieldLen[field], id)
 	}
+	delete(idx.documents, id)
 }
 
-func (idx *Index[D]) Remove(id string) {
+// Remove removes document from the index, returning removed document if it was
+// indexed.
+func (idx *Index[D]) Remove(id string) (D, bool) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
-	for field := range idx.Documents[id].Fields() {
-		for term, docs := range idx.InvIndex[field] {
-			idx.TermFreq[field][term] -= docs[id]
-			delete(docs, id)
-		}
-	}
-	delete(idx.Documents, id)
+	doc, ok := idx.documents[id]
+	idx.remove(id)
+	return doc, ok
+}
+
+// Upsert adds document to the index, replacing already indexed one with same
+// ID, which is returned if there was one.
+func (idx *Index[D]) Upsert(doc D) (D, bool) {
+	return idx.UpsertAnalyzed(Analyze(doc))
+}
+
+// UpsertAnalyzed is Upsert of already analyzed document.
+func (idx *Index[D]) UpsertAnalyzed(doc Analyzed[D]) (D, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	prev, ok := idx.documents[doc.Doc.ID()]
+	idx.add(doc)
+	return prev, ok
+}
+
+// Get returns indexed document with given ID.
+func (idx *Index[D]) Get(id string) (D, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	doc, ok := idx.documents[id]
+	return doc, ok
+}
+
+// Documents returns snapshot of all indexed documents.
+func (idx *Index[D]) Documents() map[string]D {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return maps.Clone(idx.documents)
+}
+
+// Len returns number of indexed documents.
+func (idx *Index[D]) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.documents)
 }
 
 type Hit[D Document] struct {
 	Doc   D
 	Score float64
-	Terms []Term
-	Tags  []string
+	// Terms matched by query
+	Terms []string
 }
 
-// search queries the index for the given text.
-func (idx *Index[D]) Search(query string, tags []string) []Hit[D] {
-	idx.mu.RLock()
-	defer idx.mu.RUnlock()
+func (idx *Index[D]) boost(field string) float64 {
+	if boost, ok := idx.params.boosts[field]; ok {
+		return boost
+	}
+	return idx.Weights[field]
+}
 
-	scores := map[string]float64{}
-	docTags := map[string][]string{}
+func (idx *Index[D]) avgFieldLen(field string) float64 {
+	docs := len(idx.FieldLen[field])
+	if docs == 0 {
+		return 0
+	}
 
-	queryTokens := analyze(query).ToSlice()
-	for fieldName, field := range func() D {
-		var d D
-		return d
-	}().Fields() {
-		for _, token := range queryTokens {
-			for docID, cnt := range idx.InvIndex[fieldName][token.Term] {
-				if idx.TermFreq[fieldName][token.Term] == 0 {
-					continue
+	return float64(idx.fieldTotal[field]) / float64(docs)
+}
+
+// idf returns inverse document frequency of term among documents containing
+// it in any of given fields.
+func (idx *Index[D]) idf(term string, fields []string) float64 {
+	df := 0
+	if len(fields) == 1 {
+		df = len(idx.InvIndex[fields[0]][term])
+	} else {
+		docs := map[string]struct{}{}
+		for _, field := range fields {
+			for docID := range idx.InvIndex[field][term] {
+				docs[docID] = struct{}{}
+			}
+		}
+		df = len(docs)
+	}
+
+	n := float64(len(idx.documents))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// bm25f computes BM25F scores from per field match counts and adds them to
+// scores.
+func (idx *Index[D]) bm25f(
+	counts map[string]map[string]int, // Field -> Document ID -> Match count
+	idf float64,
+	scores map[string]float64,
+) {
+	// Document ID -> field length normalized and boosted term frequency
+	tfs := map[string]float64{}
+	for field, docs := range counts {
+		boost := idx.boost(field)
+		if boost == 0 {
+			continue
+		}
+
+		avgLen := idx.avgFieldLen(field)
+		for docID, cnt := range docs {
+			norm := 1 - idx.params.b
+			if avgLen > 0 {
+				norm += idx.params.b * float64(idx.FieldLen[field][docID]) / avgLen
+			}
+			tfs[docID] += boost * float64(cnt) / norm
+		}
+	}
+
+	for docID, tf := range tfs {
+		scores[docID] += idf * tf * (idx.params.k1 + 1) / (idx.params.k1 + tf)
+	}
+}
+
+// span is a range of matched term positions in a document field, inclusive.
+type span struct {
+	start, end int
+}
+
+// spans finds matches of positional query in document field.
+func (idx *Index[D]) spans(q Query, field string) map[string][]span {
+	res := map[string][]span{}
+	switch q := q.(type) {
+	case TermQuery:
+		for docID, positions := range idx.InvIndex[field][q.Term] {
+			res[docID] = lo.Map(positions, func(pos int, _ int) span {
+				return span{pos, pos}
+			})
+		}
+	case PhraseQuery:
+	DOCS:
+		for docID, positions := range idx.InvIndex[field][q.Terms[0]] {
+			// positions of the following phrase terms
+			rest := make([]map[int]struct{}, len(q.Terms)-1)
+			for i, term := range q.Terms[1:] {
+				termPositions, ok := idx.InvIndex[field][term][docID]
+				if !ok {
+					continue DOCS
+				}
+				rest[i] = lo.SliceToMap(termPositions, func(pos int) (int, struct{}) {
+					return pos, struct{}{}
+				})
+			}
+
+		POSITIONS:
+			for _, pos := range positions {
+				for i, set := range rest {
+					if _, ok := set[pos+1+i]; !ok {
+						continue POSITIONS
+					}
 				}
 
-				scores[docID] += float64(cnt) / float64(idx.TermFreq[fieldName][token.Term]) * field.Weight
+				res[docID] = append(res[docID], span{pos, pos + len(rest)})
+			}
+		}
+	case NearQuery:
+		right := idx.spans(q.Right, field)
+		for docID, leftSpans := range idx.spans(q.Left, field) {
+			for _, l := range leftSpans {
+				for _, r := range right[docID] {
+					var gap int
+					switch {
+					case l.end < r.start:
+						gap = r.start - l.end - 1
+					case r.end < l.start:
+						gap = l.start - r.end - 1
+					default:
+						continue
+					}
+
+					if gap <= q.Distance {
+						res[docID] = append(res[docID], span{min(l.start, r.start), max(l.end, r.end)})
+					}
+				}
 			}
 		}
 	}
+	return res
+}
+
+// score computes scores of documents matching query in any of given fields.
+func (idx *Index[D]) score(q Query, fields []string) map[string]float64 {
+	scores := map[string]float64{}
+	switch q := q.(type) {
+	case TermQuery:
+		counts := map[string]map[string]int{}
+		for _, field := range fields {
+			counts[field] = lo.MapValues(idx.InvIndex[field][q.Term], func(positions []int, _ string) int {
+				return len(positions)
+			})
+		}
+		idx.bm25f(counts, idx.idf(q.Term, fields), scores)
+	case PhraseQuery, NearQuery:
+		counts := map[string]map[string]int{}
+		for _, field := range fields {
+			counts[field] = lo.MapValues(idx.spans(q, field), func(spans []span, _ string) int {
+				return len(spans)
+			})
+		}
+		idf := lo.SumBy(q.terms(), func(term string) float64 {
+			return idx.idf(term, fields)
+		})
+		idx.bm25f(counts, idf, scores)
+	case OrQuery:
+		for _, q := range q.Queries {
+			for docID, score := range idx.score(q, fields) {
+				scores[docID] += score
+			}
+		}
+	case AndQuery:
+		for i, q := range q.Queries {
+			qScores := idx.score(q, fields)
+			if i == 0 {
+				scores = qScores
+				continue
+			}
+
+			for docID, score := range scores {
+				if qScore, ok := qScores[docID]; ok {
+					scores[docID] = score + qScore
+				} else {
+					delete(scores, docID)
+				}
+			}
+		}
+	case NotQuery:
+		excluded := idx.score(q.Query, fields)
+		for docID := range idx.documents {
+			if _, ok := excluded[docID]; !ok {
+				scores[docID] = 0
+			}
+		}
+	case FieldQuery:
+		return idx.score(q.Query, []string{q.Field})
+	}
+	return scores
+}
+
+// Search queries the index, ranking matched documents using BM25F.
+func (idx *Index[D]) Search(query Query) []Hit[D] {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
 
+	scores := idx.score(query, lo.Keys(idx.InvIndex))
+	terms := lo.Uniq(query.terms())
 	return lo.MapToSlice(scores, func(id string, score float64) Hit[D] {
 		return Hit[D]{
 			Score: score,
-			Doc:   idx.Documents[id],
-			Terms: queryTokens,
-			Tags:  docTags[id],
+			Doc:   idx.documents[id],
+			Terms: terms,
 		}
 	})
 }
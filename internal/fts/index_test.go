@@ -1,10 +1,13 @@
 package fts
 
-// import (
-// 	"testing"
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"testing"
 
-// 	"github.com/stretchr/testify/assert"
-// )
+	"github.com/stretchr/testify/assert"
+)
 
 // func TestIndex(t *testing.T) {
 // 	idx := NewIndex[document]()
@@ -24,3 +27,179 @@ package fts
 // 	assert.Equal(t, idx.Search("DoNuts"), []int{1, 2})
 // 	assert.Equal(t, idx.Search("glass"), []int{1})
 // }
+
+type testDocument struct {
+	Id, Title, Text string
+}
+
+func (d testDocument) ID() string {
+	return d.Id
+}
+
+func (d testDocument) Fields() map[string]DocumentField {
+	return map[string]DocumentField{
+		"Title": {Content: d.Title, Weight: 2},
+		"Text":  {Content: d.Text, Weight: 1},
+	}
+}
+
+func search(idx *Index[testDocument], query string) []Hit[testDocument] {
+	q, err := ParseQuery(query, func(name string) (string, bool) {
+		field, ok := map[string]string{"title": "Title", "text": "Text"}[strings.ToLower(name)]
+		return field, ok
+	})
+	if err != nil {
+		panic(err)
+	}
+	return idx.Search(q)
+}
+
+func hitIDs(hits []Hit[testDocument]) []string {
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.Doc.Id
+	}
+	return ids
+}
+
+func sortedHitIDs(hits []Hit[testDocument]) []string {
+	slices.SortFunc(hits, func(i, j Hit[testDocument]) int {
+		return cmp.Compare(j.Score, i.Score)
+	})
+	return hitIDs(hits)
+}
+
+func TestSearchBM25(t *testing.T) {
+	idx := NewIndex[testDocument]()
+	idx.Add(
+		testDocument{Id: "long", Title: "groceries", Text: "donut " + strings.Repeat("milk bread eggs ", 100)},
+		testDocument{Id: "short", Title: "breakfast", Text: "donut with coffee"},
+		testDocument{Id: "none", Title: "dinner", Text: "pasta"},
+	)
+
+	assert.Equal(t, []string{"short", "long"}, sortedHitIDs(search(idx, "donut")))
+
+	idx = NewIndex[testDocument](WithFieldBoost("Title", 0))
+	idx.Add(
+		testDocument{Id: "short", Title: "breakfast", Text: "donut with coffee"},
+		testDocument{Id: "title", Title: "donut", Text: "recipe"},
+	)
+	assert.Equal(t, []string{"short"}, sortedHitIDs(search(idx, "donut")))
+}
+
+func TestAddReplacesDocument(t *testing.T) {
+	idx := NewIndex[testDocument]()
+	idx.Add(testDocument{Id: "1", Text: "donut"})
+	idx.Add(testDocument{Id: "1", Text: "glass"})
+
+	assert.Empty(t, search(idx, "donut"))
+	assert.Equal(t, []string{"1"}, hitIDs(search(idx, "glass")))
+	assert.Equal(t, 1, idx.FieldLen["Text"]["1"])
+	idx.Add(testDocument{Id: "2", Text: "plate cup"})
+	assert.Equal(t, 1.5, idx.avgFieldLen("Text"))
+
+	idx.Remove("1")
+	assert.Empty(t, search(idx, "glass"))
+	assert.Equal(t, 2.0, idx.avgFieldLen("Text"))
+	idx.Remove("2")
+	assert.Empty(t, idx.InvIndex["Text"])
+	assert.Zero(t, idx.avgFieldLen("Text"))
+}
+
+func TestUpsert(t *testing.T) {
+	idx := NewIndex[testDocument]()
+	_, ok := idx.Upsert(testDocument{Id: "1", Text: "donut"})
+	assert.False(t, ok)
+
+	prev, ok := idx.Upsert(testDocument{Id: "1", Text: "glass"})
+	assert.True(t, ok)
+	assert.Equal(t, "donut", prev.Text)
+
+	doc, ok := idx.Get("1")
+	assert.True(t, ok)
+	assert.Equal(t, "glass", doc.Text)
+
+	removed, ok := idx.Remove("1")
+	assert.True(t, ok)
+	assert.Equal(t, "glass", removed.Text)
+	assert.Zero(t, idx.Len())
+}
+
+func TestSearchPhrase(t *testing.T) {
+	idx := NewIndex[testDocument]()
+	idx.Add(
+		testDocument{Id: "adjacent", Text: "the release checklist is here"},
+		testDocument{Id: "reversed", Text: "checklist for the release"},
+		testDocument{Id: "apart", Text: "release the new checklist"},
+	)
+
+	assert.Equal(t, []string{"adjacent"}, hitIDs(search(idx, `"release checklist"`)))
+	assert.Equal(t, []string{"adjacent"}, hitIDs(search(idx, `"Releases checklists"`)))
+	assert.ElementsMatch(t, []string{"adjacent", "reversed", "apart"}, hitIDs(search(idx, `release checklist`)))
+	assert.ElementsMatch(t, []string{"adjacent"}, hitIDs(search(idx, `release NEAR/1 checklist`)))
+	assert.ElementsMatch(t, []string{"adjacent", "reversed", "apart"}, hitIDs(search(idx, `release NEAR/2 checklist`)))
+	assert.ElementsMatch(t, []string{"adjacent"}, hitIDs(search(idx, `"release checklist" NEAR/1 here`)))
+	assert.Empty(t, search(idx, `"release checklist" NEAR/0 here`))
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for query, pos := range map[string]int{
+		`foo "bar`:        4,
+		`NEAR foo`:        0,
+		`foo NEAR`:        8,
+		`foo NEAR/x a`:    4,
+		`(foo OR bar`:     0,
+		`foo) bar`:        3,
+		`foo OR`:          6,
+		`foo AND OR x`:    8,
+		`a NEAR (b OR c)`: 2,
+	} {
+		_, err := ParseQuery(query, nil)
+		var parseErr *ParseError
+		if assert.ErrorAs(t, err, &parseErr, query) {
+			assert.Equal(t, pos, parseErr.Pos, query)
+		}
+	}
+}
+
+func TestSearchBoolean(t *testing.T) {
+	idx := NewIndex[testDocument]()
+	idx.Add(
+		testDocument{Id: "1", Title: "donut", Text: "glass plate"},
+		testDocument{Id: "2", Title: "plate", Text: "donut on a plate"},
+		testDocument{Id: "3", Title: "cup", Text: "coffee"},
+	)
+
+	for query, ids := range map[string][]string{
+		`donut plate`:                        {"1", "2"},
+		`donut coffee`:                       {"1", "2", "3"},
+		`glass coffee -cup`:                  {"1"},
+		`-glass -coffee`:                     {"2"},
+		`donut AND plate glass`:              {"1", "2"},
+		`donut AND glass`:                    {"1"},
+		`donut OR coffee`:                    {"1", "2", "3"},
+		`donut -glass`:                       {"2"},
+		`NOT donut`:                          {"3"},
+		`title:donut`:                        {"1"},
+		`Title:plate OR text:coffee`:         {"2", "3"},
+		`text:(donut OR glass) -title:donut`: {"2"},
+		`(cup OR glass) AND NOT coffee`:      {"1"},
+		`title:"donut"`:                      {"1"},
+		`...`:                                {},
+	} {
+		assert.ElementsMatch(t, ids, hitIDs(search(idx, query)), query)
+	}
+}
+
+func TestSearchUnknownFieldPrefix(t *testing.T) {
+	idx := NewIndex[testDocument]()
+	idx.Add(
+		testDocument{Id: "url", Title: "links", Text: "see https://example.com for details"},
+		testDocument{Id: "todo", Title: "meeting", Text: "TODO:fix agenda at 12:30"},
+	)
+
+	assert.Equal(t, []string{"url"}, hitIDs(search(idx, `https://example.com`)))
+	assert.Equal(t, []string{"todo"}, hitIDs(search(idx, `TODO:fix`)))
+	assert.Equal(t, []string{"todo"}, hitIDs(search(idx, `12:30`)))
+	assert.Equal(t, []string{"todo"}, hitIDs(search(idx, `title:meeting`)))
+}
@@ -0,0 +1,121 @@
+package fts
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// FormatVersion is version of index on-disk format. It must be bumped on every
+// incompatible change of index structure.
+const FormatVersion = 1
+
+var ErrVersionMismatch = errors.New("index version mismatch")
+
+type header struct {
+	FormatVersion int
+	// DocumentVersion is version of documents schema, defined by user.
+	DocumentVersion int
+}
+
+type snapshot[D Document] struct {
+	InvIndex  map[string]map[string]map[string][]int
+	Documents map[string]D
+	FieldLen  map[string]map[string]int
+	Weights   map[string]float64
+}
+
+// Save writes index to w. documentVersion must be changed by user each time
+// documents structure or their fields change.
+func (idx *Index[D]) Save(w io.Writer, documentVersion int) error {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(header{
+		FormatVersion:   FormatVersion,
+		DocumentVersion: documentVersion,
+	}); err != nil {
+		return fmt.Errorf("encode header: %w", err)
+	}
+
+	if err := enc.Encode(snapshot[D]{
+		InvIndex:  idx.InvIndex,
+		Documents: idx.documents,
+		FieldLen:  idx.FieldLen,
+		Weights:   idx.Weights,
+	}); err != nil {
+		return fmt.Errorf("encode index: %w", err)
+	}
+
+	return nil
+}
+
+// Load replaces index contents with ones read from r. ErrVersionMismatch is
+// returned if index was saved with other format or documents version.
+func (idx *Index[D]) Load(r io.Reader, documentVersion int) error {
+	dec := gob.NewDecoder(r)
+
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return fmt.Errorf("decode header: %w", err)
+	}
+
+	if h.FormatVersion != FormatVersion || h.DocumentVersion != documentVersion {
+		return fmt.Errorf(
+			"saved format %d, documents %d, expected format %d, documents %d: %w",
+			h.FormatVersion, h.DocumentVersion, FormatVersion, documentVersion, ErrVersionMismatch,
+		)
+	}
+
+	var s snapshot[D]
+	if err := dec.Decode(&s); err != nil {
+		return fmt.Errorf("decode index: %w", err)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.InvIndex = s.InvIndex
+	idx.documents = s.Documents
+	idx.FieldLen = s.FieldLen
+	idx.Weights = s.Weights
+	// gob omits empty maps, so restore them
+	if idx.InvIndex == nil {
+		idx.InvIndex = map[string]map[string]map[string][]int{}
+	}
+	if idx.documents == nil {
+		idx.documents = map[string]D{}
+	}
+	if idx.FieldLen == nil {
+		idx.FieldLen = map[string]map[string]int{}
+	}
+	if idx.Weights == nil {
+		idx.Weights = map[string]float64{}
+	}
+	for field, weight := range idx.Weights {
+		idx.addField(field, weight)
+	}
+	idx.fieldTotal = map[string]int{}
+	for field, lens := range idx.FieldLen {
+		for _, l := range lens {
+			idx.fieldTotal[field] += l
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes index contents, e.g. for debugging.
+func (idx *Index[D]) MarshalJSON() ([]byte, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return json.Marshal(snapshot[D]{
+		InvIndex:  idx.InvIndex,
+		Documents: idx.documents,
+		FieldLen:  idx.FieldLen,
+		Weights:   idx.Weights,
+	})
+}
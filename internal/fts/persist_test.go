@@ -0,0 +1,28 @@
+package fts
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveLoad(t *testing.T) {
+	idx := NewIndex[testDocument]()
+	idx.Add(
+		testDocument{Id: "1", Title: "donut", Text: "glass plate"},
+		testDocument{Id: "2", Title: "plate", Text: "donut on a plate"},
+	)
+
+	var buf bytes.Buffer
+	assert.NoError(t, idx.Save(&buf, 1))
+	saved := buf.Bytes()
+
+	loaded := NewIndex[testDocument]()
+	assert.NoError(t, loaded.Load(bytes.NewReader(saved), 1))
+	assert.Equal(t, idx.documents, loaded.documents)
+	assert.Equal(t, idx.fieldTotal, loaded.fieldTotal)
+	assert.ElementsMatch(t, hitIDs(search(idx, `"glass plate" OR donut`)), hitIDs(search(loaded, `"glass plate" OR donut`)))
+
+	assert.ErrorIs(t, NewIndex[testDocument]().Load(bytes.NewReader(saved), 2), ErrVersionMismatch)
+}
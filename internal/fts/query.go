@@ -0,0 +1,456 @@
+package fts
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Query is a parsed search query.
+type Query interface {
+	// terms returns all terms matched by query.
+	terms() []string
+}
+
+// TermQuery matches documents containing term.
+type TermQuery struct {
+	Term string
+}
+
+// PhraseQuery matches documents containing terms adjacent in given order.
+type PhraseQuery struct {
+	Terms []string
+}
+
+// NearQuery matches documents where Left and Right matches are separated
+// by at most Distance other terms, in any order.
+type NearQuery struct {
+	Left, Right Query
+	Distance    int
+}
+
+// OrQuery matches documents matching any of Queries.
+type OrQuery struct {
+	Queries []Query
+}
+
+// AndQuery matches documents matching all of Queries.
+type AndQuery struct {
+	Queries []Query
+}
+
+// NotQuery matches documents not matching Query.
+type NotQuery struct {
+	Query Query
+}
+
+// FieldQuery matches documents matching Query in Field only.
+type FieldQuery struct {
+	Field string
+	Query Query
+}
+
+func (q TermQuery) terms() []string {
+	return []string{q.Term}
+}
+
+func (q PhraseQuery) terms() []string {
+	return q.Terms
+}
+
+func (q NearQuery) terms() []string {
+	return append(q.Left.terms(), q.Right.terms()...)
+}
+
+func (q OrQuery) terms() []string {
+	res := []string{}
+	for _, q := range q.Queries {
+		res = append(res, q.terms()...)
+	}
+	return res
+}
+
+func (q AndQuery) terms() []string {
+	res := []string{}
+	for _, q := range q.Queries {
+		res = append(res, q.terms()...)
+	}
+	return res
+}
+
+func (q NotQuery) terms() []string {
+	return nil
+}
+
+func (q FieldQuery) terms() []string {
+	return q.Query.terms()
+}
+
+// DefaultNearDistance is distance used by NEAR operator without explicit /n.
+const DefaultNearDistance = 5
+
+// ParseError describes invalid query syntax.
+type ParseError struct {
+	// Pos is byte offset of the problem in query.
+	Pos int
+	Msg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parse query at position %d: %s", e.Pos, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenPhrase
+	tokenNear
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	// field prefix, e.g. "title:"
+	tokenField
+	tokenEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// distance of NEAR operator
+	distance int
+	// index field of field prefix
+	field string
+}
+
+var _reFieldPrefix = regexp.MustCompile(`^([A-Za-z][\w.]*):`)
+
+// lex splits query into words, quoted phrases and operators. Prefixes like
+// "name:" are field prefixes only if field resolves name, so that e.g. urls
+// and times are searched as words.
+func lex(query string, field func(name string) (string, bool)) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '"':
+			j := strings.IndexByte(query[i+1:], '"')
+			if j == -1 {
+				return nil, &ParseError{Pos: i, Msg: "unterminated quote"}
+			}
+
+			tokens = append(tokens, token{kind: tokenPhrase, text: query[i+1 : i+1+j], pos: i})
+			i += j + 2
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+		case r == '-' && i+1 < len(query) && !strings.ContainsRune(" \t\r\n)-", rune(query[i+1])):
+			tokens = append(tokens, token{kind: tokenNot, text: "-", pos: i})
+			i++
+		default:
+			if m := _reFieldPrefix.FindStringSubmatch(query[i:]); m != nil && i+len(m[0]) < len(query) {
+				if r, _ := utf8.DecodeRuneInString(query[i+len(m[0]):]); !unicode.IsSpace(r) {
+					if f, ok := field(m[1]); ok {
+						tokens = append(tokens, token{kind: tokenField, text: m[1], pos: i, field: f})
+						i += len(m[0])
+						continue
+					}
+				}
+			}
+
+			j := i
+			for j < len(query) {
+				r, size := utf8.DecodeRuneInString(query[j:])
+				if unicode.IsSpace(r) || strings.ContainsRune(`"()`, r) {
+					break
+				}
+				j += size
+			}
+
+			word := query[i:j]
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokenAnd, text: word, pos: i})
+			case "OR":
+				tokens = append(tokens, token{kind: tokenOr, text: word, pos: i})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokenNot, text: word, pos: i})
+			default:
+				if rest, ok := strings.CutPrefix(word, "NEAR"); ok && (rest == "" || rest[0] == '/') {
+					distance := DefaultNearDistance
+					if rest != "" {
+						n, err := strconv.Atoi(rest[1:])
+						if err != nil || n < 0 {
+							return nil, &ParseError{Pos: i, Msg: fmt.Sprintf("invalid NEAR distance %q", rest[1:])}
+						}
+						distance = n
+					}
+
+					tokens = append(tokens, token{kind: tokenNear, text: word, pos: i, distance: distance})
+				} else {
+					tokens = append(tokens, token{kind: tokenWord, text: word, pos: i})
+				}
+			}
+			i = j
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(query)}), nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func unexpected(t token) *ParseError {
+	if t.kind == tokenEOF {
+		return &ParseError{Pos: t.pos, Msg: "unexpected end of query"}
+	}
+	return &ParseError{Pos: t.pos, Msg: fmt.Sprintf("unexpected %q", t.text)}
+}
+
+// textQuery makes query from analyzed text, multiple terms are treated as
+// phrase. Returns nil if text has no terms.
+func textQuery(text string) Query {
+	terms := Terms(text)
+	switch len(terms) {
+	case 0:
+		return nil
+	case 1:
+		return TermQuery{Term: terms[0]}
+	default:
+		return PhraseQuery{Terms: terms}
+	}
+}
+
+// parsePrimary parses word, phrase, parenthesized or field scoped query.
+// Returns nil query if it has no terms, e.g. consists of punctuation only.
+func (p *parser) parsePrimary() (Query, error) {
+	switch t := p.next(); t.kind {
+	case tokenWord, tokenPhrase:
+		return textQuery(t.text), nil
+	case tokenLParen:
+		q, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokenRParen {
+			if closing.kind == tokenEOF {
+				return nil, &ParseError{Pos: t.pos, Msg: "unclosed parenthesis"}
+			}
+			return nil, unexpected(closing)
+		}
+		return q, nil
+	case tokenField:
+		q, err := p.parsePrimary()
+		if err != nil || q == nil {
+			return nil, err
+		}
+		return FieldQuery{Field: t.field, Query: q}, nil
+	default:
+		return nil, unexpected(t)
+	}
+}
+
+func isPositional(q Query) bool {
+	switch q.(type) {
+	case TermQuery, PhraseQuery, NearQuery:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseNear parses operands joined by NEAR operators.
+func (p *parser) parseNear() (Query, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokenNear {
+		near := p.next()
+		if !isPositional(left) {
+			return nil, &ParseError{Pos: near.pos, Msg: "NEAR must be preceded by a term or phrase"}
+		}
+
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if !isPositional(right) {
+			return nil, &ParseError{Pos: near.pos, Msg: "NEAR must be followed by a term or phrase"}
+		}
+
+		left = NearQuery{Left: left, Right: right, Distance: near.distance}
+	}
+	return left, nil
+}
+
+// parseUnary parses possibly negated query.
+func (p *parser) parseUnary() (Query, error) {
+	if p.peek().kind != tokenNot {
+		return p.parseNear()
+	}
+
+	p.next()
+	q, err := p.parseUnary()
+	if err != nil || q == nil {
+		return nil, err
+	}
+	return NotQuery{Query: q}, nil
+}
+
+func startsUnary(t token) bool {
+	switch t.kind {
+	case tokenWord, tokenPhrase, tokenNot, tokenLParen, tokenField:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseAnd parses queries joined by AND operator.
+func (p *parser) parseAnd() (Query, error) {
+	res := AndQuery{}
+	for {
+		q, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if q != nil {
+			res.Queries = append(res.Queries, q)
+		}
+
+		if p.peek().kind != tokenAnd {
+			break
+		}
+		p.next()
+	}
+
+	switch len(res.Queries) {
+	case 0:
+		return nil, nil
+	case 1:
+		return res.Queries[0], nil
+	default:
+		return res, nil
+	}
+}
+
+// parseOr parses queries joined by OR operator or just juxtaposed. Negated
+// operands exclude matches of the others, so "a b -c" matches documents
+// having a or b, but not c.
+func (p *parser) parseOr() (Query, error) {
+	res := OrQuery{}
+	excluded := []Query{}
+	for {
+		q, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := q.(NotQuery); ok {
+			excluded = append(excluded, q)
+		} else if q != nil {
+			res.Queries = append(res.Queries, q)
+		}
+
+		if p.peek().kind == tokenOr {
+			p.next()
+			continue
+		}
+		if !startsUnary(p.peek()) {
+			break
+		}
+	}
+
+	var q Query
+	switch len(res.Queries) {
+	case 0:
+	case 1:
+		q = res.Queries[0]
+	default:
+		q = res
+	}
+
+	switch {
+	case len(excluded) == 0:
+		return q, nil
+	case q == nil && len(excluded) == 1:
+		return excluded[0], nil
+	case q == nil:
+		return AndQuery{Queries: excluded}, nil
+	default:
+		return AndQuery{Queries: append([]Query{q}, excluded...)}, nil
+	}
+}
+
+// ParseQuery parses search query. Query consists of words and quoted phrases,
+// any of which must be found in a document for it to match. Supported
+// operators are, in order of decreasing precedence:
+//
+//   - field:query, matches query in given field only, field names are
+//     resolved to index fields using field function, unknown ones are
+//     searched as part of the word
+//   - a NEAR/n b, matches if words or phrases are separated by at most n other
+//     words, n defaults to DefaultNearDistance
+//   - NOT a, -a, matches documents not matching a
+//   - a AND b, matches documents matching both
+//   - a OR b, a b, matches documents matching any, except ones matching
+//     negated operands, e.g. "a -b" matches documents having a, but not b
+//
+// Parentheses can be used for grouping.
+func ParseQuery(query string, field func(name string) (string, bool)) (Query, error) {
+	if field == nil {
+		field = func(string) (string, bool) {
+			return "", false
+		}
+	}
+
+	tokens, err := lex(query, field)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	q, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		if t.kind == tokenRParen {
+			return nil, &ParseError{Pos: t.pos, Msg: "unmatched closing parenthesis"}
+		}
+		return nil, unexpected(t)
+	}
+
+	if q == nil {
+		return OrQuery{}, nil
+	}
+	return q, nil
+}
@@ -56,6 +56,16 @@ func tokenize(s string) iter.Seq[Term] {
 	}
 }
 
+// Terms returns terms text is indexed by.
+func Terms(text string) []string {
+	terms := []string{}
+	analyze(text)(func(term Term) bool {
+		terms = append(terms, term.Term)
+		return true
+	})
+	return terms
+}
+
 // analyze analyzes the text and returns a slice of tokens.
 func analyze(text string) iter.Seq[Term] {
 	return tokenize(text).
@@ -0,0 +1,427 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// _gitDefaultAuthor is author of commits made by unauthenticated users.
+const _gitDefaultAuthor = "flatnotes"
+
+// _gitExcludes are service directories which must not be committed.
+var _gitExcludes = []string{".flatnotes/", _historyDir + "/", _trashDir + "/"}
+
+// gitChange is a change of notes to be committed.
+type gitChange struct {
+	author  string
+	message string
+	// changed paths relative to notes directory
+	paths Set[string]
+}
+
+// Git commits changes of notes made through App to git repository of notes
+// directory, also it serves history of notes from git log. Content updates are
+// committed after no more updates came for batchDelay, so series of saves
+// result in single commit. Other changes are committed at once.
+type Git struct {
+	dir        string
+	batchDelay time.Duration
+
+	mu sync.Mutex
+	// batched updates, nil if there are none
+	pending *gitChange
+	timer   *time.Timer
+}
+
+// newGit prepares notes directory to be used with git, initializing
+// repository if there is none.
+func newGit(dir string, batchDelay time.Duration) (*Git, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("find git: %w", err)
+	}
+
+	g := &Git{
+		dir:        dir,
+		batchDelay: batchDelay,
+	}
+
+	if _, err := g.run(nil, "", "rev-parse", "--is-inside-work-tree"); err != nil {
+		if _, err := g.run(nil, "", "init", "--quiet"); err != nil {
+			return nil, fmt.Errorf("init repository: %w", err)
+		}
+		log.Printf("initialized git repository in %q\n", dir)
+	}
+
+	excludePath, err := g.run(nil, "", "rev-parse", "--git-path", "info/exclude")
+	if err != nil {
+		return nil, fmt.Errorf("get exclude file path: %w", err)
+	}
+
+	excludePath = strings.TrimSpace(excludePath)
+	if !filepath.IsAbs(excludePath) {
+		excludePath = filepath.Join(dir, excludePath)
+	}
+
+	if err := g.exclude(excludePath); err != nil {
+		return nil, fmt.Errorf("exclude service directories: %w", err)
+	}
+
+	return g, nil
+}
+
+// exclude adds service directories to git exclude file, if they are not there
+// yet.
+func (g *Git) exclude(excludePath string) error {
+	content, err := os.ReadFile(excludePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	missing := lo.Filter(_gitExcludes, func(pattern string, _ int) bool {
+		return !slices.Contains(lines, pattern)
+	})
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+		content = append(content, '\n')
+	}
+	content = append(content, strings.Join(missing, "\n")+"\n"...)
+
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(excludePath, content, 0o644)
+}
+
+// run runs git command in notes directory, returning its output. Commits are
+// authored by author, if not empty.
+func (g *Git) run(stdin []byte, author string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = append(os.Environ(),
+		"GIT_LITERAL_PATHSPECS=1",
+		// paths with non ASCII characters are printed as is
+		"GIT_CONFIG_COUNT=1",
+		"GIT_CONFIG_KEY_0=core.quotePath",
+		"GIT_CONFIG_VALUE_0=false",
+	)
+	if author != "" {
+		cmd.Env = append(cmd.Env,
+			"GIT_AUTHOR_NAME="+author,
+			"GIT_AUTHOR_EMAIL=",
+			"GIT_COMMITTER_NAME="+_gitDefaultAuthor,
+			"GIT_COMMITTER_EMAIL=",
+		)
+	}
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// commit commits current state of changed paths.
+func (g *Git) commit(change gitChange) error {
+	paths := lo.Keys(change.paths)
+	slices.Sort(paths)
+
+	// git fails on paths which are neither in work tree nor in index, e.g.
+	// old path of renamed note which was never committed
+	out, err := g.run(nil, "", append([]string{"ls-files", "-z", "--"}, paths...)...)
+	if err != nil {
+		return err
+	}
+	tracked := strings.Split(out, "\x00")
+	paths = lo.Filter(paths, func(path string, _ int) bool {
+		_, err := os.Stat(filepath.Join(g.dir, path))
+		return err == nil || slices.Contains(tracked, path)
+	})
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if _, err := g.run(nil, "", append([]string{"add", "--all", "--"}, paths...)...); err != nil {
+		return err
+	}
+
+	// there might be nothing to commit, e.g. if note was saved without changes
+	if _, err := g.run(nil, "", append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		return nil
+	}
+
+	args := append([]string{"commit", "--quiet", "--no-verify", "--message", change.message, "--"}, paths...)
+	_, err = g.run(nil, change.author, args...)
+	return err
+}
+
+// flush commits pending batched updates, must be called with mu locked.
+func (g *Git) flush() error {
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+
+	if g.pending == nil {
+		return nil
+	}
+
+	change := *g.pending
+	g.pending = nil
+	return g.commit(change)
+}
+
+// Flush commits pending batched updates.
+func (g *Git) Flush() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.flush()
+}
+
+// Commit commits changed paths at once, together with pending updates.
+func (g *Git) Commit(author, message string, paths ...string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.flush(); err != nil {
+		return fmt.Errorf("commit pending updates: %w", err)
+	}
+
+	return g.commit(gitChange{
+		author:  author,
+		message: message,
+		paths:   lo.SliceToMap(paths, func(path string) (string, struct{}) { return path, struct{}{} }),
+	})
+}
+
+// CommitBatched schedules commit of updated note. Updates from same author
+// are batched into single commit.
+func (g *Git) CommitBatched(author, title string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.pending != nil && g.pending.author != author {
+		if err := g.flush(); err != nil {
+			log.Println("commit pending updates:", err.Error())
+		}
+	}
+
+	if g.pending == nil {
+		g.pending = &gitChange{
+			author: author,
+			paths:  Set[string]{},
+		}
+	}
+
+	g.pending.paths[title+_markdownExt] = struct{}{}
+	titles := lo.Map(lo.Keys(g.pending.paths), func(path string, _ int) string {
+		return strings.TrimSuffix(path, _markdownExt)
+	})
+	slices.Sort(titles)
+	g.pending.message = "Update " + strings.Join(titles, ", ")
+
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.timer = time.AfterFunc(g.batchDelay, func() {
+		if err := g.Flush(); err != nil {
+			log.Println("commit pending updates:", err.Error())
+		}
+	})
+}
+
+// gitVersion is a commit changing note.
+type gitVersion struct {
+	NoteVersion
+	// path of note at commit, relative to repository root
+	path string
+}
+
+// rev returns object name of note content at version. Deleted note content is
+// taken from parent commit.
+func (v gitVersion) rev() string {
+	if v.Action == HistoryActionDelete {
+		return v.ID + "^:" + v.path
+	}
+	return v.ID + ":" + v.path
+}
+
+// versions lists commits changing note, following renames, newest first.
+func (g *Git) versions(title string) ([]gitVersion, error) {
+	if err := g.Flush(); err != nil {
+		return nil, fmt.Errorf("commit pending updates: %w", err)
+	}
+
+	// repository without commits has no log
+	if _, err := g.run(nil, "", "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return nil, nil
+	}
+
+	out, err := g.run(nil, "", "log", "--follow", "--name-status", "--format=commit %H %at", "--", title+_markdownExt)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []gitVersion{}
+	for _, line := range strings.Split(out, "\n") {
+		if rest, ok := strings.CutPrefix(line, "commit "); ok {
+			hash, timestamp, _ := strings.Cut(rest, " ")
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse commit %s time %q: %w", hash, timestamp, err)
+			}
+
+			res = append(res, gitVersion{NoteVersion: NoteVersion{
+				ID:   hash,
+				Time: time.Unix(unix, 0),
+			}})
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 2 || len(res) == 0 {
+			continue
+		}
+
+		version := &res[len(res)-1]
+		version.path = fields[len(fields)-1]
+		switch fields[0][0] {
+		case 'A':
+			version.Action = HistoryActionCreate
+		case 'D':
+			version.Action = HistoryActionDelete
+		case 'R', 'C':
+			version.Action = HistoryActionRename
+		default:
+			version.Action = HistoryActionUpdate
+		}
+	}
+
+	// sizes of all versions are got at once
+	var revs bytes.Buffer
+	for _, version := range res {
+		revs.WriteString(version.rev() + "\n")
+	}
+
+	out, err = g.run(revs.Bytes(), "", "cat-file", "--batch-check=%(objectsize)")
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for i := 0; i < len(res) && scanner.Scan(); i++ {
+		// missing objects are reported as "<rev> missing"
+		if size, err := strconv.ParseInt(scanner.Text(), 10, 64); err == nil {
+			res[i].Size = size
+		}
+	}
+	return res, nil
+}
+
+// Save does nothing, versions are committed as notes change.
+func (g *Git) Save(string, []byte, []byte, HistoryAction) error {
+	return nil
+}
+
+// Rename does nothing, git log follows renames.
+func (g *Git) Rename(string, string) error {
+	return nil
+}
+
+func (g *Git) List(title string) ([]NoteVersion, error) {
+	versions, err := g.versions(title)
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(versions, func(version gitVersion, _ int) NoteVersion {
+		return version.NoteVersion
+	}), nil
+}
+
+func (g *Git) Get(title, id string) ([]byte, error) {
+	versions, err := g.versions(title)
+	if err != nil {
+		return nil, err
+	}
+
+	version, ok := lo.Find(versions, func(version gitVersion) bool {
+		return version.ID == id
+	})
+	if !ok {
+		return nil, ErrVersionNotFound
+	}
+
+	content, err := g.run(nil, "", "cat-file", "blob", version.rev())
+	if err != nil {
+		return nil, err
+	}
+	return []byte(content), nil
+}
+
+// Prune does nothing, commits are never removed.
+func (g *Git) Prune() error {
+	return nil
+}
+
+// commit commits changes of notes with given titles, if git mode is enabled.
+// Failures are only logged as notes are changed already, uncommitted changes
+// get into next commit of the note.
+func (app *App) commit(ctx context.Context, message string, titles ...string) {
+	if app.Git == nil {
+		return
+	}
+
+	paths := lo.Map(titles, func(title string, _ int) string {
+		return title + _markdownExt
+	})
+	if err := app.Git.Commit(authorFrom(ctx), message, paths...); err != nil {
+		log.Printf("commit %q: %s\n", message, err.Error())
+	}
+}
+
+// flushCommits commits pending updates, if git mode is enabled. It is called
+// before note is moved or deleted, so that updates are committed to the path
+// they were made at, otherwise they would be committed as part of the move.
+func (app *App) flushCommits() {
+	if app.Git == nil {
+		return
+	}
+
+	if err := app.Git.Flush(); err != nil {
+		log.Println("commit pending updates:", err.Error())
+	}
+}
+
+// commitUpdate schedules commit of note content update, if git mode is
+// enabled.
+func (app *App) commitUpdate(ctx context.Context, title string) {
+	if app.Git == nil {
+		return
+	}
+
+	app.Git.CommitBatched(authorFrom(ctx), title)
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHistory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	dir := t.TempDir()
+	g, err := newGit(dir, time.Hour)
+	require.NoError(t, err)
+
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "note.md"), []byte(content), 0o644))
+	}
+
+	write("one")
+	require.NoError(t, g.Commit("user", "Create note", "note.md"))
+	for _, content := range []string{"two", "three"} {
+		write(content)
+		g.CommitBatched("user", "note")
+	}
+
+	versions, err := g.List("note")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, HistoryActionUpdate, versions[0].Action)
+	assert.Equal(t, HistoryActionCreate, versions[1].Action)
+
+	content, err := g.Get("note", versions[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(content))
+
+	author, err := g.run(nil, "", "log", "-1", "--format=%an %s")
+	require.NoError(t, err)
+	assert.Equal(t, "user Update note\n", author)
+}
@@ -0,0 +1,465 @@
+package internal
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+var ErrVersionNotFound = fmt.Errorf("The specified version cannot be found.")
+
+// _historyDir is directory in notes directory where previous versions of
+// notes are kept.
+const _historyDir = ".history"
+
+// HistoryAction is a change which made note version.
+type HistoryAction string
+
+const (
+	HistoryActionCreate  HistoryAction = "create"
+	HistoryActionUpdate  HistoryAction = "update"
+	HistoryActionRename  HistoryAction = "rename"
+	HistoryActionDelete  HistoryAction = "delete"
+	HistoryActionRestore HistoryAction = "restore"
+)
+
+// NoteVersion is a version of note, its content after a change. Version made
+// by deletion has last content of note.
+type NoteVersion struct {
+	ID string
+	// Time of change
+	Time   time.Time
+	Action HistoryAction
+	Size   int64
+}
+
+// History keeps versions of notes.
+type History interface {
+	// Save stores content of note after action. prev is content before
+	// action, nil if it is not known or not needed, e.g. for new notes.
+	// Backends storing contents themselves store prev too, if it is not
+	// latest version, e.g. note was changed outside of flatnotes, so it is
+	// not lost.
+	Save(title string, prev, content []byte, action HistoryAction) error
+	// Rename moves versions of note to its new title.
+	Rename(oldTitle, newTitle string) error
+	// List returns versions of note, newest first.
+	List(title string) ([]NoteVersion, error)
+	// Get returns content of note version.
+	Get(title, id string) ([]byte, error)
+	// Prune removes versions exceeding retention limits.
+	Prune() error
+}
+
+// fsHistory keeps versions of note as files in its directory inside hidden
+// history directory. Files are named by time of change and action.
+type fsHistory struct {
+	dir string
+	// Maximum number of versions kept per note, 0 for unlimited
+	maxVersions int
+	// Maximum age of kept versions, 0 for unlimited
+	maxAge time.Duration
+}
+
+func newFSHistory(notesDir string, maxVersions int, maxAge time.Duration) *fsHistory {
+	return &fsHistory{
+		dir:         filepath.Join(notesDir, _historyDir),
+		maxVersions: maxVersions,
+		maxAge:      maxAge,
+	}
+}
+
+var _reVersionFilename = regexp.MustCompile(`^(\d+)-(\w+)` + regexp.QuoteMeta(_markdownExt) + `$`)
+
+// noteDir returns directory with versions of note. It is named as note file,
+// so titles like ".." cannot point outside of history directory.
+func (h *fsHistory) noteDir(title string) string {
+	return noteFilepath(h.dir, title)
+}
+
+func (h *fsHistory) Save(title string, prev, content []byte, action HistoryAction) error {
+	dir := h.noteDir(title)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("create history directory: %w", err)
+	}
+
+	if prev != nil {
+		versions, err := h.versions(dir)
+		if err != nil {
+			return err
+		}
+
+		prevAction := HistoryActionUpdate
+		if len(versions) == 0 {
+			prevAction = HistoryActionCreate
+		}
+
+		if len(versions) == 0 || !h.hasContent(dir, versions[0], prev) {
+			if err := h.save(dir, prev, prevAction); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := h.save(dir, content, action); err != nil {
+		return err
+	}
+
+	return h.prune(dir)
+}
+
+// hasContent reports whether version of note directory has given content.
+func (h *fsHistory) hasContent(dir string, version NoteVersion, content []byte) bool {
+	versionContent, err := os.ReadFile(h.versionFilepath(dir, version))
+	return err == nil && bytes.Equal(versionContent, content)
+}
+
+// save stores version file in note directory.
+func (h *fsHistory) save(dir string, content []byte, action HistoryAction) error {
+	// versions are saved with O_EXCL, so concurrent saves get distinct ids
+	for id := time.Now().UnixNano(); ; id++ {
+		filename := filepath.Join(dir, fmt.Sprintf("%d-%s%s", id, action, _markdownExt))
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("create version file: %w", err)
+		}
+
+		if _, err := f.Write(content); err != nil {
+			f.Close()
+			return fmt.Errorf("write version %q: %w", filename, err)
+		}
+
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("close version %q: %w", filename, err)
+		}
+		return nil
+	}
+}
+
+// versions lists version files of note directory, newest first.
+func (h *fsHistory) versions(dir string) ([]NoteVersion, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read history directory: %w", err)
+	}
+
+	res := []NoteVersion{}
+	for _, entry := range entries {
+		m := _reVersionFilename.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+
+		nanos, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("stat version %q: %w", entry.Name(), err)
+		}
+
+		res = append(res, NoteVersion{
+			ID:     m[1],
+			Time:   time.Unix(0, nanos),
+			Action: HistoryAction(m[2]),
+			Size:   info.Size(),
+		})
+	}
+
+	slices.SortFunc(res, func(a, b NoteVersion) int {
+		return b.Time.Compare(a.Time)
+	})
+	return res, nil
+}
+
+func (h *fsHistory) versionFilepath(dir string, version NoteVersion) string {
+	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", version.ID, version.Action, _markdownExt))
+}
+
+func (h *fsHistory) List(title string) ([]NoteVersion, error) {
+	return h.versions(h.noteDir(title))
+}
+
+func (h *fsHistory) Get(title, id string) ([]byte, error) {
+	dir := h.noteDir(title)
+	versions, err := h.versions(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range versions {
+		if version.ID == id {
+			return os.ReadFile(h.versionFilepath(dir, version))
+		}
+	}
+	return nil, ErrVersionNotFound
+}
+
+func (h *fsHistory) Rename(oldTitle, newTitle string) error {
+	oldDir, newDir := h.noteDir(oldTitle), h.noteDir(newTitle)
+	versions, err := h.versions(oldDir)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(newDir, 0o755); err != nil {
+		return fmt.Errorf("create history directory: %w", err)
+	}
+
+	// versions are moved one by one, as note might already have history from
+	// before it was renamed or deleted
+	for _, version := range versions {
+		if err := os.Rename(h.versionFilepath(oldDir, version), h.versionFilepath(newDir, version)); err != nil {
+			return fmt.Errorf("move version %s: %w", version.ID, err)
+		}
+	}
+
+	if err := os.Remove(oldDir); err != nil {
+		return fmt.Errorf("remove history directory: %w", err)
+	}
+
+	return h.prune(newDir)
+}
+
+// prune removes versions of note directory exceeding retention limits.
+func (h *fsHistory) prune(dir string) error {
+	versions, err := h.versions(dir)
+	if err != nil {
+		return err
+	}
+
+	for i, version := range versions {
+		if (h.maxVersions > 0 && i >= h.maxVersions) || (h.maxAge > 0 && time.Since(version.Time) > h.maxAge) {
+			if err := os.Remove(h.versionFilepath(dir, version)); err != nil {
+				return fmt.Errorf("remove version %s: %w", version.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (h *fsHistory) Prune() error {
+	if h.maxVersions <= 0 && h.maxAge <= 0 {
+		return nil
+	}
+
+	err := filepath.WalkDir(h.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() || filepath.Ext(path) != _markdownExt {
+			return nil
+		}
+
+		return h.prune(path)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// saveVersion stores content of note after action in history. Failures are
+// only logged as note is changed already.
+func (app *App) saveVersion(title string, prev, content []byte, action HistoryAction) {
+	if err := app.History.Save(title, prev, content, action); err != nil {
+		log.Printf("save note %q version: %s\n", title, err.Error())
+	}
+}
+
+func newNoteVersionModel(version NoteVersion) NoteVersionModel {
+	return NoteVersionModel{
+		ID:        version.ID,
+		Timestamp: version.Time.Unix(),
+		Action:    version.Action,
+		Size:      version.Size,
+	}
+}
+
+// versionCursor is a position in note history.
+type versionCursor struct {
+	Timestamp int64 `json:"t"`
+	// Number of older versions, orders versions made within a second
+	Older int `json:"o"`
+}
+
+// compareVersionCursors orders versions newest first.
+func compareVersionCursors(a, b versionCursor) int {
+	if c := cmp.Compare(b.Timestamp, a.Timestamp); c != 0 {
+		return c
+	}
+	return cmp.Compare(b.Older, a.Older)
+}
+
+// GetHistory returns page of at most limit versions of note following cursor,
+// newest first. History of deleted notes is available too.
+func (app *App) GetHistory(title string, limit int, cursor string) (ListResponseModel[NoteVersionModel], error) {
+	if !app.validTitle(title) {
+		return ListResponseModel[NoteVersionModel]{}, ErrTitleInvalid
+	}
+
+	versions, err := app.History.List(title)
+	if err != nil {
+		return ListResponseModel[NoteVersionModel]{}, fmt.Errorf("list note %q versions: %w", title, err)
+	}
+
+	if len(versions) == 0 && !ospathexists(noteFilepath(app.Dir, title)) {
+		return ListResponseModel[NoteVersionModel]{}, ErrNotFound
+	}
+
+	res := make([]NoteVersionModel, len(versions))
+	older := make(map[string]int, len(versions))
+	for i, version := range versions {
+		res[i] = newNoteVersionModel(version)
+		older[version.ID] = len(versions) - 1 - i
+	}
+
+	key := func(version NoteVersionModel) versionCursor {
+		return versionCursor{
+			Timestamp: version.Timestamp,
+			Older:     older[version.ID],
+		}
+	}
+	// commit times in git history are not always in order
+	slices.SortFunc(res, func(a, b NoteVersionModel) int {
+		return compareVersionCursors(key(a), key(b))
+	})
+	return paginateList(res, key, compareVersionCursors, limit, cursor)
+}
+
+// GetVersion returns version of note.
+func (app *App) GetVersion(title, id string) (NoteVersionContentModel, error) {
+	if !app.validTitle(title) {
+		return NoteVersionContentModel{}, ErrTitleInvalid
+	}
+
+	versions, err := app.History.List(title)
+	if err != nil {
+		return NoteVersionContentModel{}, fmt.Errorf("list note %q versions: %w", title, err)
+	}
+
+	version, ok := lo.Find(versions, func(version NoteVersion) bool {
+		return version.ID == id
+	})
+	if !ok {
+		return NoteVersionContentModel{}, ErrVersionNotFound
+	}
+
+	content, err := app.History.Get(title, id)
+	if err != nil {
+		return NoteVersionContentModel{}, fmt.Errorf("get note %q version %s: %w", title, id, err)
+	}
+
+	return NoteVersionContentModel{
+		NoteVersionModel: newNoteVersionModel(version),
+		Content:          string(content),
+	}, nil
+}
+
+// versionContent returns content of note version, or of current note if id
+// is empty.
+func (app *App) versionContent(title, id string) (string, error) {
+	if id != "" {
+		version, err := app.GetVersion(title, id)
+		return version.Content, err
+	}
+
+	note, err := app.GetNote(title, true)
+	if err != nil {
+		return "", err
+	}
+	return *note.Content, nil
+}
+
+// DiffVersions returns unified diff between versions of note with given ids.
+// Empty id stands for current version.
+func (app *App) DiffVersions(title, fromID, toID string) (string, error) {
+	from, err := app.versionContent(title, fromID)
+	if err != nil {
+		return "", err
+	}
+
+	to, err := app.versionContent(title, toID)
+	if err != nil {
+		return "", err
+	}
+
+	name := func(id string) string {
+		if id == "" {
+			return title
+		}
+		return title + "@" + id
+	}
+	return unifiedDiff(name(fromID), name(toID), from, to), nil
+}
+
+// RestoreVersion replaces note content with content of its version. Deleted
+// note is recreated. If ifMatch is not
+// empty, existing note is changed only if its current version matches it.
+func (app *App) RestoreVersion(ctx context.Context, title, id, ifMatch string) (NoteContentResponseModel, error) {
+	version, err := app.GetVersion(title, id)
+	if err != nil {
+		return NoteContentResponseModel{}, err
+	}
+
+	app.writeMu.Lock()
+	note, err := app.getNote(title)
+	if errors.Is(err, ErrNotFound) {
+		app.writeMu.Unlock()
+		// fails with ErrTitleExists if note is created meanwhile
+		return app.CreateNote(ctx, NotePostModel{
+			Title:   title,
+			Content: version.Content,
+		})
+	}
+	defer app.writeMu.Unlock()
+	if err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("get note %q: %w", title, err)
+	}
+
+	if err := app.checkVersion(title, ifMatch); err != nil {
+		return NoteContentResponseModel{}, err
+	}
+
+	content, err := note.GetContent()
+	if err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("get note %q content: %w", title, err)
+	}
+
+	if err := note.SetContent([]byte(version.Content)); err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("set note %q content: %w", title, err)
+	}
+
+	app.saveVersion(title, content, []byte(version.Content), HistoryActionRestore)
+
+	if err := app.syncNote(title); err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("index note %q: %w", title, err)
+	}
+
+	app.commit(ctx, fmt.Sprintf("Restore %s to version %s", title, id), title)
+
+	return app.GetNote(title, true)
+}
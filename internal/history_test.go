@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFSHistory(t *testing.T) {
+	h := newFSHistory(t.TempDir(), 2, 0)
+	for _, content := range []string{"one", "two", "three"} {
+		require.NoError(t, h.Save("note", nil, []byte(content), HistoryActionUpdate))
+	}
+	require.NoError(t, h.Rename("note", "renamed"))
+
+	versions, err := h.List("note")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+
+	versions, err = h.List("renamed")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	content, err := h.Get("renamed", versions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "three", string(content))
+
+	_, err = h.Get("renamed", "1")
+	assert.Equal(t, ErrVersionNotFound, err)
+
+	// content changed outside of flatnotes is kept
+	h = newFSHistory(t.TempDir(), 0, 0)
+	require.NoError(t, h.Save("note", []byte("external"), []byte("one"), HistoryActionUpdate))
+	require.NoError(t, h.Save("note", []byte("one"), []byte("two"), HistoryActionUpdate))
+	require.NoError(t, h.Save("note", []byte("edited"), []byte("three"), HistoryActionUpdate))
+
+	versions, err = h.List("note")
+	require.NoError(t, err)
+	assert.Equal(t, []HistoryAction{
+		HistoryActionUpdate,
+		HistoryActionUpdate,
+		HistoryActionUpdate,
+		HistoryActionUpdate,
+		HistoryActionCreate,
+	}, lo.Map(versions, func(version NoteVersion, _ int) HistoryAction {
+		return version.Action
+	}))
+
+	contents := lo.Map(versions, func(version NoteVersion, _ int) string {
+		content, err := h.Get("note", version.ID)
+		require.NoError(t, err)
+		return string(content)
+	})
+	assert.Equal(t, []string{"three", "edited", "two", "one", "external"}, contents)
+}
+
+// TestHistoryBackends checks that versions mean the same for all history
+// backends: content of note after change.
+func TestHistoryBackends(t *testing.T) {
+	for name, setup := range map[string]func(t *testing.T, app *App){
+		"fs": func(*testing.T, *App) {},
+		"git": func(t *testing.T, app *App) {
+			if _, err := exec.LookPath("git"); err != nil {
+				t.Skip("git is not available")
+			}
+
+			g, err := newGit(app.Dir, time.Hour)
+			require.NoError(t, err)
+			app.History = g
+			app.Git = g
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			app := newTestApp(t)
+			setup(t, app)
+
+			_, err := app.CreateNote(ctx, NotePostModel{Title: "note", Content: "one"})
+			require.NoError(t, err)
+			_, err = app.UpdateNote(ctx, "note", NotePatchModel{NewContent: lo.ToPtr("two")}, "")
+			require.NoError(t, err)
+			_, err = app.UpdateNote(ctx, "note", NotePatchModel{NewTitle: lo.ToPtr("renamed")}, "")
+			require.NoError(t, err)
+			require.NoError(t, app.DeleteNote(ctx, "renamed", ""))
+
+			versions, err := app.GetHistory("renamed", 0, "")
+			require.NoError(t, err)
+
+			type version struct {
+				Action  HistoryAction
+				Content string
+			}
+			assert.Equal(t, []version{
+				{HistoryActionDelete, "two"},
+				{HistoryActionRename, "two"},
+				{HistoryActionUpdate, "two"},
+				{HistoryActionCreate, "one"},
+			}, lo.Map(versions.Items, func(v NoteVersionModel, _ int) version {
+				content, err := app.GetVersion("renamed", v.ID)
+				require.NoError(t, err)
+				return version{v.Action, content.Content}
+			}))
+		})
+	}
+}
@@ -0,0 +1,488 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/samber/lo"
+)
+
+var (
+	// [[Title]], [[Title|alias]] or [[Title#heading]], submatch is title
+	_reWikiLink = regexp.MustCompile(`\[\[([^\[\]|#\n]+)(?:#[^\[\]|\n]*)?(?:\|[^\[\]\n]*)?\]\]`)
+	// [text](note.md) or [text](<note with spaces.md>), optionally with
+	// #heading, submatches are angle bracketed and plain paths
+	_reMarkdownLink = regexp.MustCompile(`\]\((?:<([^<>\n]+?\.md)(?:#[^<>\n]*)?>|([^()\s<>]+?\.md)(?:#[^()\s]*)?)\)`)
+)
+
+// markdownLinkTarget resolves path of markdown link in note with given title
+// to title of linked note. External and absolute links are not resolved.
+func markdownLinkTarget(title, target string) (string, bool) {
+	if strings.Contains(target, "://") || strings.HasPrefix(target, "/") {
+		return "", false
+	}
+
+	target, err := url.PathUnescape(target)
+	if err != nil {
+		return "", false
+	}
+
+	target = path.Join(path.Dir(title), target)
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return "", false
+	}
+
+	return strings.TrimSuffix(target, _markdownExt), true
+}
+
+// extractLinks returns titles of notes linked from content of note with given
+// title, by wiki links or relative markdown links, in order of appearance.
+func extractLinks(title, content string) []string {
+	_, body, _ := splitFrontMatter(content)
+	body = _reCodeblocks.ReplaceAllLiteralString(body, "")
+
+	type occurrence struct {
+		pos    int
+		target string
+	}
+	occurrences := []occurrence{}
+	for _, m := range _reWikiLink.FindAllStringSubmatchIndex(body, -1) {
+		if target := strings.TrimSpace(body[m[2]:m[3]]); target != "" {
+			occurrences = append(occurrences, occurrence{m[0], target})
+		}
+	}
+	for _, m := range _reMarkdownLink.FindAllStringSubmatchIndex(body, -1) {
+		raw := ""
+		if m[2] != -1 {
+			raw = body[m[2]:m[3]]
+		} else {
+			raw = body[m[4]:m[5]]
+		}
+
+		if target, ok := markdownLinkTarget(title, raw); ok {
+			occurrences = append(occurrences, occurrence{m[0], target})
+		}
+	}
+
+	slices.SortFunc(occurrences, func(a, b occurrence) int {
+		return a.pos - b.pos
+	})
+	return lo.Uniq(lo.Map(occurrences, func(o occurrence, _ int) string {
+		return o.target
+	}))
+}
+
+// linkGraph keeps links between notes, both existing and not.
+type linkGraph struct {
+	mu sync.RWMutex
+	// Note title -> Titles of notes it links to
+	outgoing map[string][]string
+	// Note title -> Titles of notes linking to it
+	incoming map[string]Set[string]
+}
+
+func newLinkGraph() *linkGraph {
+	return &linkGraph{
+		outgoing: map[string][]string{},
+		incoming: map[string]Set[string]{},
+	}
+}
+
+func (g *linkGraph) remove(source string) {
+	for _, target := range g.outgoing[source] {
+		delete(g.incoming[target], source)
+		if len(g.incoming[target]) == 0 {
+			delete(g.incoming, target)
+		}
+	}
+	delete(g.outgoing, source)
+}
+
+// Set replaces links of source note.
+func (g *linkGraph) Set(source string, targets []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.remove(source)
+	if len(targets) == 0 {
+		return
+	}
+
+	g.outgoing[source] = targets
+	for _, target := range targets {
+		if _, ok := g.incoming[target]; !ok {
+			g.incoming[target] = Set[string]{}
+		}
+		g.incoming[target][source] = struct{}{}
+	}
+}
+
+// Remove removes links of source note.
+func (g *linkGraph) Remove(source string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.remove(source)
+}
+
+// Links returns titles of notes linked from source note.
+func (g *linkGraph) Links(source string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return slices.Clone(g.outgoing[source])
+}
+
+// Backlinks returns sorted titles of notes linking to target note.
+func (g *linkGraph) Backlinks(target string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	res := lo.Keys(g.incoming[target])
+	slices.SortFunc(res, compareTitles)
+	return res
+}
+
+// All returns links of all notes.
+func (g *linkGraph) All() map[string][]string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return lo.MapValues(g.outgoing, func(targets []string, _ string) []string {
+		return slices.Clone(targets)
+	})
+}
+
+// indexAdd adds documents to index and their links to link graph, publishing
+// events for new and changed notes. Must be called with indexMu locked.
+func (app *App) indexAdd(docs ...NoteDocument) {
+	for _, doc := range docs {
+		prev, ok := app.Index.Upsert(doc)
+		app.links.Set(doc.Title, doc.Links)
+
+		switch {
+		case !ok:
+			app.publish(newEvent(EventCreated, doc))
+		case !prev.Modtime.Equal(doc.Modtime) || prev.Size != doc.Size:
+			app.publish(newEvent(EventUpdated, doc))
+		}
+	}
+}
+
+// indexRemove removes note from index and link graph. Must be called with
+// indexMu locked.
+func (app *App) indexRemove(title string) {
+	doc, ok := app.Index.Remove(title)
+	app.links.Remove(title)
+
+	if ok {
+		event := newEvent(EventDeleted, doc)
+		event.LastModified = 0
+		event.ETag = ""
+		app.publish(event)
+	}
+}
+
+// indexRename replaces note with oldTitle in index with renamed one. Must be
+// called with indexMu locked.
+func (app *App) indexRename(oldTitle string, doc NoteDocument) {
+	app.Index.Remove(oldTitle)
+	app.links.Remove(oldTitle)
+	app.Index.Upsert(doc)
+	app.links.Set(doc.Title, doc.Links)
+
+	event := newEvent(EventRenamed, doc)
+	event.OldTitle = oldTitle
+	app.publish(event)
+}
+
+func (app *App) noteExists(title string) bool {
+	_, ok := app.Index.Get(title)
+	return ok
+}
+
+// GetBacklinks returns page of at most limit notes linking to note with given
+// title following cursor, ordered by title. Note itself does not have to
+// exist.
+func (app *App) GetBacklinks(title string, limit int, cursor string) (ListResponseModel[NoteResponseModel], error) {
+	if !app.validTitle(title) {
+		return ListResponseModel[NoteResponseModel]{}, ErrTitleInvalid
+	}
+
+	res := []NoteResponseModel{}
+	for _, source := range app.links.Backlinks(title) {
+		doc, ok := app.Index.Get(source)
+		if !ok {
+			continue
+		}
+
+		res = append(res, NoteResponseModel{
+			Title:        source,
+			LastModified: doc.Modtime.Unix(),
+		})
+	}
+
+	return paginateList(res, func(note NoteResponseModel) string {
+		return note.Title
+	}, compareTitles, limit, cursor)
+}
+
+// GetLinks returns links from note with given title.
+func (app *App) GetLinks(title string) ([]LinkModel, error) {
+	if !app.validTitle(title) {
+		return nil, ErrTitleInvalid
+	}
+
+	if !app.noteExists(title) {
+		return nil, ErrNotFound
+	}
+
+	return lo.Map(app.links.Links(title), func(target string, _ int) LinkModel {
+		return LinkModel{
+			Title:  target,
+			Exists: app.noteExists(target),
+		}
+	}), nil
+}
+
+// GetUnresolvedLinks returns page of at most limit links to notes which do not
+// exist following cursor, ordered by source and target.
+func (app *App) GetUnresolvedLinks(limit int, cursor string) (ListResponseModel[UnresolvedLinkModel], error) {
+	res := []UnresolvedLinkModel{}
+	for source, targets := range app.links.All() {
+		for _, target := range targets {
+			if !app.noteExists(target) {
+				res = append(res, UnresolvedLinkModel{
+					Source: source,
+					Target: target,
+				})
+			}
+		}
+	}
+
+	compare := func(a, b UnresolvedLinkModel) int {
+		if c := compareTitles(a.Source, b.Source); c != 0 {
+			return c
+		}
+		return compareTitles(a.Target, b.Target)
+	}
+	slices.SortFunc(res, compare)
+	return paginateList(res, func(link UnresolvedLinkModel) UnresolvedLinkModel {
+		return link
+	}, compare, limit, cursor)
+}
+
+// relativeLink returns path of markdown link from note source to note target.
+// Path in angle brackets is kept as is, otherwise it is escaped.
+func relativeLink(source, target string, angle bool) string {
+	rel, err := filepath.Rel(filepath.FromSlash(path.Dir(source)), filepath.FromSlash(target))
+	if err != nil {
+		rel = target
+	}
+
+	rel = filepath.ToSlash(rel) + _markdownExt
+	if angle {
+		return rel
+	}
+
+	segments := strings.Split(rel, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// rewriteLinks rewrites links in content of note moved from oldSource to
+// newSource, so that links to oldTitle point to newTitle and other relative
+// links still point to same notes. Reports whether content was changed.
+func rewriteLinks(content, oldSource, newSource, oldTitle, newTitle string) (string, bool) {
+	_, body, _ := splitFrontMatter(content)
+	offset := len(content) - len(body)
+
+	codeblocks := _reCodeblocks.FindAllStringIndex(body, -1)
+	inCode := func(pos int) bool {
+		return slices.ContainsFunc(codeblocks, func(block []int) bool {
+			return block[0] <= pos && pos < block[1]
+		})
+	}
+
+	type replacement struct {
+		start, end int
+		text       string
+	}
+	replacements := []replacement{}
+	for _, m := range _reWikiLink.FindAllStringSubmatchIndex(body, -1) {
+		if !inCode(m[0]) && strings.TrimSpace(body[m[2]:m[3]]) == oldTitle {
+			replacements = append(replacements, replacement{m[2], m[3], newTitle})
+		}
+	}
+
+	moved := path.Dir(oldSource) != path.Dir(newSource)
+	for _, m := range _reMarkdownLink.FindAllStringSubmatchIndex(body, -1) {
+		if inCode(m[0]) {
+			continue
+		}
+
+		start, end, angle := m[2], m[3], true
+		if start == -1 {
+			start, end, angle = m[4], m[5], false
+		}
+
+		target, ok := markdownLinkTarget(oldSource, body[start:end])
+		if !ok || target != oldTitle && !moved {
+			continue
+		}
+
+		if target == oldTitle {
+			target = newTitle
+		}
+		replacements = append(replacements, replacement{start, end, relativeLink(newSource, target, angle)})
+	}
+
+	if len(replacements) == 0 {
+		return content, false
+	}
+
+	slices.SortFunc(replacements, func(a, b replacement) int {
+		return b.start - a.start
+	})
+	for _, r := range replacements {
+		body = body[:r.start] + r.text + body[r.end:]
+	}
+
+	res := content[:offset] + body
+	return res, res != content
+}
+
+// linkRewrite is a pending rewrite of links in note. New content is written to
+// temporary file, which replaces note file when rewrite is applied.
+type linkRewrite struct {
+	note Note
+	// content before and after rewrite
+	content, newContent []byte
+	tmpPath             string
+}
+
+// prepareLinkRewrites writes contents of notes linking to oldTitle, with links
+// pointing to newTitle, to temporary files. Must be called with writeMu
+// locked, until rewrites are applied or discarded.
+func (app *App) prepareLinkRewrites(oldTitle, newTitle string) ([]linkRewrite, error) {
+	res := []linkRewrite{}
+	for _, source := range app.links.Backlinks(oldTitle) {
+		if source == oldTitle {
+			continue
+		}
+
+		rewrite, ok, err := app.prepareLinkRewrite(source, oldTitle, newTitle)
+		if err != nil {
+			discardLinkRewrites(res)
+			return nil, fmt.Errorf("rewrite links of %q: %w", source, err)
+		}
+
+		if ok {
+			res = append(res, rewrite)
+		}
+	}
+	return res, nil
+}
+
+func (app *App) prepareLinkRewrite(source, oldTitle, newTitle string) (linkRewrite, bool, error) {
+	note, err := app.getNote(source)
+	if errors.Is(err, ErrNotFound) {
+		return linkRewrite{}, false, nil
+	} else if err != nil {
+		return linkRewrite{}, false, err
+	}
+
+	content, err := note.GetContent()
+	if err != nil {
+		return linkRewrite{}, false, fmt.Errorf("get content: %w", err)
+	}
+
+	newContent, changed := rewriteLinks(string(content), source, source, oldTitle, newTitle)
+	if !changed {
+		return linkRewrite{}, false, nil
+	}
+
+	// temporary file is hidden and has no markdown extension, so it is not
+	// taken for note
+	notePath := noteFilepath(app.Dir, source)
+	f, err := os.CreateTemp(filepath.Dir(notePath), "."+filepath.Base(notePath)+".*.tmp")
+	if err != nil {
+		return linkRewrite{}, false, fmt.Errorf("create temporary file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(newContent); err != nil {
+		os.Remove(f.Name())
+		return linkRewrite{}, false, fmt.Errorf("write temporary file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return linkRewrite{}, false, fmt.Errorf("close temporary file: %w", err)
+	}
+
+	return linkRewrite{
+		note:       note,
+		content:    content,
+		newContent: []byte(newContent),
+		tmpPath:    f.Name(),
+	}, true, nil
+}
+
+func discardLinkRewrites(rewrites []linkRewrite) {
+	for _, rewrite := range rewrites {
+		os.Remove(rewrite.tmpPath)
+	}
+}
+
+// applyLinkRewrites replaces notes with their rewritten versions, all or
+// none of them: if one fails, notes replaced already are reverted. Must be
+// called with writeMu locked, temporary files of rewrites not applied are left
+// for discardLinkRewrites.
+func (app *App) applyLinkRewrites(rewrites []linkRewrite) error {
+	for i, rewrite := range rewrites {
+		if err := os.Rename(rewrite.tmpPath, noteFilepath(app.Dir, rewrite.note.Title)); err != nil {
+			revertLinkRewrites(rewrites[:i])
+			return fmt.Errorf("rewrite links of %q: %w", rewrite.note.Title, err)
+		}
+	}
+	return nil
+}
+
+// revertLinkRewrites restores contents of notes replaced by applyLinkRewrites.
+func revertLinkRewrites(rewrites []linkRewrite) {
+	for _, rewrite := range rewrites {
+		if err := rewrite.note.SetContent(rewrite.content); err != nil {
+			log.Printf("revert links of %q: %s\n", rewrite.note.Title, err.Error())
+		}
+	}
+}
+
+// finishLinkRewrites records applied rewrites in history and index. Returns
+// titles of changed notes.
+func (app *App) finishLinkRewrites(rewrites []linkRewrite) []string {
+	res := make([]string, len(rewrites))
+	for i, rewrite := range rewrites {
+		title := rewrite.note.Title
+		app.saveVersion(title, rewrite.content, rewrite.newContent, HistoryActionUpdate)
+
+		// note is changed already, index is synced eventually anyway
+		if err := app.syncNote(title); err != nil {
+			log.Printf("index note %q: %s\n", title, err.Error())
+		}
+
+		res[i] = title
+	}
+	return res
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractLinks(t *testing.T) {
+	content := "---\nlink: \"[[Front]]\"\n---\n" +
+		"See [[Other note]], [[Aliased|shown text]] and [[Heading#Section]].\n" +
+		"Also [relative](sibling.md), [nested](<sub dir/deep note.md#top>), [escaped](My%20Note.md),\n" +
+		"[up](../top.md), [outside](../../x.md), [web](https://example.com/a.md), [[Other note]] again.\n" +
+		"`[[In code]]`\n"
+
+	assert.Equal(t, []string{
+		"Other note",
+		"Aliased",
+		"Heading",
+		"folder/sibling",
+		"folder/sub dir/deep note",
+		"folder/My Note",
+		"top",
+	}, extractLinks("folder/note", content))
+}
+
+func TestLinkGraph(t *testing.T) {
+	g := newLinkGraph()
+	g.Set("a", []string{"b", "c"})
+	g.Set("d", []string{"b"})
+	assert.Equal(t, []string{"a", "d"}, g.Backlinks("b"))
+
+	g.Set("a", []string{"c"})
+	assert.Equal(t, []string{"d"}, g.Backlinks("b"))
+
+	g.Remove("d")
+	assert.Empty(t, g.Backlinks("b"))
+	assert.Equal(t, []string{"c"}, g.Links("a"))
+}
+
+func TestRewriteLinks(t *testing.T) {
+	content := "---\nlink: \"[[Old]]\"\n---\n" +
+		"[[Old]], [[ Old |alias]], [[Old#Section]], [[Older]],\n" +
+		"[md](Old.md), [angle](<Old.md#top>), [other](Other.md), [web](https://example.com/Old.md)\n" +
+		"`[[Old]]`\n"
+
+	res, changed := rewriteLinks(content, "note", "note", "Old", "dir/New name")
+	assert.True(t, changed)
+	assert.Equal(t, "---\nlink: \"[[Old]]\"\n---\n"+
+		"[[dir/New name]], [[dir/New name|alias]], [[dir/New name#Section]], [[Older]],\n"+
+		"[md](dir/New%20name.md), [angle](<dir/New name.md#top>), [other](Other.md), [web](https://example.com/Old.md)\n"+
+		"`[[Old]]`\n", res)
+
+	// relative links of moved note keep pointing to same notes
+	res, changed = rewriteLinks("[self](Old.md) [other](Other.md) [[Other]]", "Old", "dir/New", "Old", "dir/New")
+	assert.True(t, changed)
+	assert.Equal(t, "[self](New.md) [other](../Other.md) [[Other]]", res)
+
+	_, changed = rewriteLinks("[[Other]]", "note", "note", "Old", "New")
+	assert.False(t, changed)
+}
+
+func TestRenameUpdatesLinks(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+	app.Recursive = true
+	dir := app.Dir
+
+	for title, content := range map[string]string{
+		"old":       "[[other]]",
+		"other":     "see [[old]]",
+		"sub/third": "see [old](../old.md)",
+		"taken":     "",
+	} {
+		_, err := app.CreateNote(ctx, NotePostModel{Title: title, Content: content})
+		require.NoError(t, err)
+	}
+
+	_, err := app.UpdateNote(ctx, "old", NotePatchModel{NewTitle: lo.ToPtr("taken"), UpdateLinks: true}, "")
+	assert.Equal(t, ErrTitleExists, err)
+
+	other, err := app.GetNote("other", true)
+	require.NoError(t, err)
+	assert.Equal(t, "see [[old]]", *other.Content)
+
+	res, err := app.UpdateNote(ctx, "old", NotePatchModel{NewTitle: lo.ToPtr("new"), UpdateLinks: true}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other", "sub/third"}, res.UpdatedNotes)
+
+	other, err = app.GetNote("other", true)
+	require.NoError(t, err)
+	assert.Equal(t, "see [[new]]", *other.Content)
+
+	third, err := app.GetNote("sub/third", true)
+	require.NoError(t, err)
+	assert.Equal(t, "see [old](../new.md)", *third.Content)
+
+	backlinks, err := app.GetBacklinks("new", 0, "")
+	require.NoError(t, err)
+	assert.Len(t, backlinks.Items, 2)
+
+	for _, pattern := range []string{".*.tmp", "*/.*.tmp"} {
+		tmpFiles, err := filepath.Glob(filepath.Join(dir, pattern))
+		require.NoError(t, err)
+		assert.Empty(t, tmpFiles)
+	}
+
+	// one of rewrites fails, so none are applied
+	rewrites, err := app.prepareLinkRewrites("new", "newer")
+	require.NoError(t, err)
+	require.Len(t, rewrites, 2)
+	require.NoError(t, os.Remove(rewrites[1].tmpPath))
+
+	assert.Error(t, app.applyLinkRewrites(rewrites))
+	discardLinkRewrites(rewrites)
+
+	other, err = app.GetNote("other", true)
+	require.NoError(t, err)
+	assert.Equal(t, "see [[new]]", *other.Content)
+
+	third, err = app.GetNote("sub/third", true)
+	require.NoError(t, err)
+	assert.Equal(t, "see [old](../new.md)", *third.Content)
+}
@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// storeImage stores image in attachments directory under name derived from
+// its content, so same images are stored once. Like any attachment, image
+// must be of allowed type and not larger than maximum size.
+func (app *App) storeImage(image []byte) (AttachmentModel, error) {
+	if int64(len(image)) > app.AttachmentMaxSize {
+		return AttachmentModel{}, ErrAttachmentTooLarge
+	}
+
+	// type is detected from content, type declared in data URI is not trusted
+	contentType := detectContentType("", image)
+	if !strings.HasPrefix(contentType, "image/") || !allowedType(contentType, app.AttachmentTypes) {
+		return AttachmentModel{}, ErrAttachmentNotAllowed
+	}
+
+	hash := sha256.Sum256(image)
+	filename := attachmentFilename("image-"+hex.EncodeToString(hash[:8]), contentType)
+
+	if err := os.MkdirAll(app.attachmentsDir(), 0o755); err != nil {
+		return AttachmentModel{}, fmt.Errorf("create attachments directory: %w", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(app.attachmentsDir(), filename), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		// same image is stored already
+		return app.newAttachmentModel(filename, int64(len(image)), contentType), nil
+	} else if err != nil {
+		return AttachmentModel{}, fmt.Errorf("create image file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(image); err != nil {
+		return AttachmentModel{}, fmt.Errorf("write image %q: %w", filename, err)
+	}
+
+	if err := f.Close(); err != nil {
+		return AttachmentModel{}, fmt.Errorf("close image %q: %w", filename, err)
+	}
+
+	return app.newAttachmentModel(filename, int64(len(image)), contentType), nil
+}
+
+// extractImages moves inline base64 images of content into attachments,
+// replacing them with links. Returns new content and number of images moved.
+// Images which cannot be decoded or are not allowed as attachments are left as
+// is.
+func (app *App) extractImages(content string) (string, int, error) {
+	count := 0
+	var err error
+	res := _reImageBase64.ReplaceAllStringFunc(content, func(match string) string {
+		if err != nil {
+			return match
+		}
+
+		submatches := _reImageBase64.FindStringSubmatch(match)
+		alt, encoded := submatches[1], submatches[3]
+
+		image, decodeErr := base64.StdEncoding.DecodeString(encoded)
+		if decodeErr != nil {
+			return match
+		}
+
+		attachment, storeErr := app.storeImage(image)
+		if errors.Is(storeErr, ErrAttachmentTooLarge) || errors.Is(storeErr, ErrAttachmentNotAllowed) {
+			return match
+		} else if storeErr != nil {
+			err = storeErr
+			return match
+		}
+
+		count++
+		return fmt.Sprintf("![%s](%s)", alt, attachment.URL)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return res, count, nil
+}
+
+// prepareContent transforms note content before it is saved.
+func (app *App) prepareContent(content string) (string, error) {
+	if !app.ExtractImages {
+		return content, nil
+	}
+
+	content, _, err := app.extractImages(content)
+	if err != nil {
+		return "", fmt.Errorf("extract images: %w", err)
+	}
+	return content, nil
+}
+
+// MigrateImages moves inline base64 images of all notes into attachments.
+// Returns number of changed notes and moved images.
+func (app *App) MigrateImages(ctx context.Context) (int, int, error) {
+	notes, err := app.getNotes()
+	if err != nil {
+		return 0, 0, fmt.Errorf("get notes: %w", err)
+	}
+
+	totalNotes, totalImages := 0, 0
+	for _, note := range notes {
+		images, err := app.migrateNoteImages(ctx, note)
+		if err != nil {
+			return totalNotes, totalImages, err
+		}
+
+		if images == 0 {
+			continue
+		}
+
+		log.Printf("%d images moved out of %q\n", images, note.Title)
+		totalNotes++
+		totalImages += images
+	}
+	return totalNotes, totalImages, nil
+}
+
+// migrateNoteImages moves inline base64 images of note into attachments.
+// Returns number of moved images.
+func (app *App) migrateNoteImages(ctx context.Context, note Note) (int, error) {
+	app.writeMu.Lock()
+	defer app.writeMu.Unlock()
+
+	content, err := note.GetContent()
+	if errors.Is(err, fs.ErrNotExist) {
+		// removed meanwhile
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("get note %q content: %w", note.Title, err)
+	}
+
+	newContent, images, err := app.extractImages(string(content))
+	if err != nil {
+		return 0, fmt.Errorf("extract note %q images: %w", note.Title, err)
+	}
+
+	if images == 0 {
+		return 0, nil
+	}
+
+	if err := note.SetContent([]byte(newContent)); err != nil {
+		return 0, fmt.Errorf("set note %q content: %w", note.Title, err)
+	}
+
+	app.saveVersion(note.Title, content, []byte(newContent), HistoryActionUpdate)
+
+	if err := app.syncNote(note.Title); err != nil {
+		return 0, fmt.Errorf("index note %q: %w", note.Title, err)
+	}
+
+	app.commitUpdate(ctx, note.Title)
+	return images, nil
+}
@@ -0,0 +1,29 @@
+package internal
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractImages(t *testing.T) {
+	app := newTestApp(t)
+	app.AttachmentMaxSize = 1 << 10
+	app.AttachmentTypes = []string{"image/gif"}
+	image := base64.StdEncoding.EncodeToString([]byte("GIF89a image"))
+	html := base64.StdEncoding.EncodeToString([]byte("<html><script>alert(1)</script></html>"))
+	content := "a ![one](data:image/gif;base64," + image + ") b ![two](data:image/html;base64," + image + ") ![bad](data:image/gif;base64,AAAA) ![html](data:image/png;base64," + html + ")"
+
+	got, count, err := app.extractImages(content)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, "a ![one](static/image-9e1dc1bc88696746.gif) b ![two](static/image-9e1dc1bc88696746.gif) ![bad](data:image/gif;base64,AAAA) ![html](data:image/png;base64,"+html+")", got)
+
+	files, err := os.ReadDir(filepath.Join(app.Dir, _staticDir))
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
@@ -36,12 +36,66 @@ type NoteResponseModel struct {
 
 type NoteContentResponseModel struct {
 	NoteResponseModel
-	Content *string `json:"content"`
+	Content  *string       `json:"content"`
+	Metadata *NoteMetadata `json:"metadata,omitempty"`
+	// Version of note content, as in ETag header
+	ETag string `json:"etag"`
+	// Titles of notes whose links were rewritten on rename
+	UpdatedNotes []string `json:"updatedNotes,omitempty"`
 }
 
 type NotePatchModel struct {
 	NewTitle   *string `json:"newTitle"`
 	NewContent *string `json:"newContent"`
+	// Rewrite links to note in other notes if it is renamed
+	UpdateLinks bool `json:"updateLinks"`
+}
+
+type NoteVersionModel struct {
+	ID string `json:"id"`
+	// Time of change which made version
+	Timestamp int64         `json:"timestamp"`
+	Action    HistoryAction `json:"action"`
+	Size      int64         `json:"size"`
+}
+
+type NoteVersionContentModel struct {
+	NoteVersionModel
+	Content string `json:"content"`
+}
+
+type LinkModel struct {
+	Title string `json:"title"`
+	// Whether linked note exists
+	Exists bool `json:"exists"`
+}
+
+type UnresolvedLinkModel struct {
+	// Title of note containing link
+	Source string `json:"source"`
+	// Title of linked note which does not exist
+	Target string `json:"target"`
+}
+
+type AttachmentModel struct {
+	Filename string `json:"filename"`
+	URL      string `json:"url"`
+	// Markdown link to attachment, to be inserted into note
+	Markdown    string `json:"markdown"`
+	Size        int64  `json:"size"`
+	ContentType string `json:"contentType"`
+}
+
+type TrashedNoteModel struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	DeletedAt int64  `json:"deletedAt"`
+	Size      int64  `json:"size"`
+}
+
+type TrashRestoreModel struct {
+	// Title to restore note under, original title is used if nil
+	NewTitle *string `json:"newTitle"`
 }
 
 type SearchResultModel struct {
@@ -53,6 +107,70 @@ type SearchResultModel struct {
 	TagMatches        []string `json:"tagMatches"`
 }
 
+type SearchResponseModel struct {
+	Results []SearchResultModel `json:"results"`
+	// Total number of hits on all pages. It is approximate: notes deleted
+	// since indexing are counted until index is synced, though they are
+	// dropped from pages
+	Total int `json:"total"`
+	// Cursor of the next page, nil on last page
+	NextCursor *string `json:"next_cursor"`
+	// Whether initial indexing is not finished, so some notes might be missing
+	// or outdated
+	Partial bool `json:"partial"`
+}
+
+// StatusModel is progress of initial indexing.
+type StatusModel struct {
+	Indexing bool `json:"indexing"`
+	// Number of indexed notes
+	Notes int `json:"notes"`
+	// Number of notes indexed and to be indexed by initial indexing
+	Done  int `json:"done"`
+	Total int `json:"total"`
+	// Seconds since initial indexing started, while it is in progress
+	Elapsed float64 `json:"elapsedSeconds,omitempty"`
+	// Estimated seconds until initial indexing is finished
+	ETA *float64 `json:"etaSeconds,omitempty"`
+	// Why initial indexing failed
+	Error string `json:"error,omitempty"`
+}
+
+// ListResponseModel is a page of listing.
+type ListResponseModel[T any] struct {
+	Items []T `json:"items"`
+	// Cursor of the next page, nil on last page
+	NextCursor *string `json:"next_cursor"`
+}
+
 type ConfigModel struct {
 	AuthType AuthType `json:"authType"`
+	// URL path prefix flatnotes is served under, empty if it is served at root
+	PathPrefix string `json:"pathPrefix"`
+}
+
+// ErrorResponseModel is body of error responses.
+type ErrorResponseModel struct {
+	Message string `json:"message"`
+	// Machine readable error code, e.g. "note_not_found"
+	Code string `json:"code"`
+	// Current version of note on version mismatch
+	Current *NoteContentResponseModel `json:"current,omitempty"`
+	// Position of error in search query
+	Position *int `json:"position,omitempty"`
+}
+
+// WebhookDeliveryModel is attempt to deliver event to webhook.
+type WebhookDeliveryModel struct {
+	Timestamp int64     `json:"timestamp"`
+	URL       string    `json:"url"`
+	EventID   uint64    `json:"eventId"`
+	Event     EventType `json:"event"`
+	Title     string    `json:"title"`
+	Attempt   int       `json:"attempt"`
+	// Response status, 0 if request failed
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// Request duration in milliseconds
+	Duration int64 `json:"duration"`
 }
@@ -1,7 +1,11 @@
 package internal
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -14,7 +18,7 @@ import (
 
 func ospathexists(path string) bool {
 	_, err := os.Stat(path)
-	return !os.IsNotExist(err)
+	return !errors.Is(err, fs.ErrNotExist)
 }
 
 const _markdownExt = ".md"
@@ -32,22 +36,36 @@ type NoteDocument struct {
 	Content string
 	Tags    Set[string]
 	Modtime time.Time
+	// Size of note file in bytes
+	Size int64
+	// Aliases declared in front matter
+	Aliases []string
+	// Front matter key -> Searchable text of value
+	Meta map[string]string
+	// Titles of linked notes
+	Links []string
 }
 
+// _metaFieldPrefix prefixes front matter keys to get index field name.
+const _metaFieldPrefix = "meta."
+
 func (d NoteDocument) ID() string {
 	return d.Title
 }
 
-var _reImageBase64 = regexp.MustCompile(`!\[[^\[\]]*\]\(data:image/\w+;base64,[a-zA-Z0-9+/=]+\)`)
+// _reImageBase64 matches inline image, submatches are alt text, image subtype
+// and base64 encoded image.
+var _reImageBase64 = regexp.MustCompile(`!\[([^\[\]]*)\]\(data:image/(\w+);base64,([a-zA-Z0-9+/=]+)\)`)
 
 func (d NoteDocument) Fields() map[string]fts.DocumentField {
-	return map[string]fts.DocumentField{
+	_, body, _ := splitFrontMatter(d.Content)
+	fields := map[string]fts.DocumentField{
 		"Title": {
 			Content: d.Title,
 			Weight:  2,
 		},
 		"Content": {
-			Content: _reImageBase64.ReplaceAllString(d.Content, ""),
+			Content: _reImageBase64.ReplaceAllString(body, ""),
 			Weight:  1,
 		},
 		"Tags": {
@@ -55,7 +73,35 @@ func (d NoteDocument) Fields() map[string]fts.DocumentField {
 			Weight:  4,
 			Terms:   lo.Keys(d.Tags),
 		},
+		"Aliases": {
+			Content: strings.Join(d.Aliases, "\n"),
+			Weight:  2,
+		},
+	}
+	for key, value := range d.Meta {
+		fields[_metaFieldPrefix+key] = fts.DocumentField{
+			Content: value,
+			Weight:  1,
+		}
+	}
+	return fields
+}
+
+// contentETag returns strong entity tag of note content.
+func contentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches checks whether entity tag matches If-Match header value.
+func etagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
 	}
+	return false
 }
 
 type Note struct {
@@ -64,7 +110,7 @@ type Note struct {
 }
 
 func noteFilepath(dir, title string) string {
-	return filepath.Join(dir, title+_markdownExt)
+	return filepath.Join(dir, filepath.FromSlash(title)+_markdownExt)
 }
 
 func createNote(dir, title, content string) (Note, time.Time, error) {
@@ -73,11 +119,15 @@ func createNote(dir, title, content string) (Note, time.Time, error) {
 		NotesDir: dir,
 	}
 
-	filepath := noteFilepath(dir, note.Title)
+	notePath := noteFilepath(dir, note.Title)
 
-	noteFile, err := os.OpenFile(filepath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err := os.MkdirAll(filepath.Dir(notePath), 0o755); err != nil {
+		return Note{}, time.Time{}, fmt.Errorf("create note directory: %w", err)
+	}
+
+	noteFile, err := os.OpenFile(notePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
 	if err != nil {
-		if os.IsExist(err) {
+		if errors.Is(err, fs.ErrExist) {
 			return Note{}, time.Time{}, ErrTitleExists
 		}
 
@@ -104,7 +154,11 @@ func toDocument(note Note) (NoteDocument, error) {
 		return NoteDocument{}, fmt.Errorf("get content %q: %w", note.Title, err)
 	}
 
-	_, tags := extractTags(string(content))
+	metadata, frontMatterTags, body := parseFrontMatter(string(content))
+	_, tags := extractTags(body)
+	for tag := range frontMatterTags {
+		tags[tag] = struct{}{}
+	}
 
 	modtime, err := note.LastModified()
 	if err != nil {
@@ -116,6 +170,10 @@ func toDocument(note Note) (NoteDocument, error) {
 		Content: string(content),
 		Tags:    tags,
 		Modtime: modtime,
+		Size:    int64(len(content)),
+		Aliases: metadata.Aliases,
+		Meta:    metadata.searchableFields(),
+		Links:   extractLinks(note.Title, string(content)),
 	}, nil
 }
 
@@ -129,10 +187,30 @@ func (n Note) LastModified() (time.Time, error) {
 	return stat.ModTime(), nil
 }
 
+// titleTaken reports whether note file newPath exists and is not oldPath,
+// e.g. when title only changes case on case insensitive filesystem.
+func titleTaken(oldPath, newPath string) bool {
+	newInfo, err := os.Stat(newPath)
+	if err != nil {
+		return false
+	}
+
+	oldInfo, err := os.Stat(oldPath)
+	return err != nil || !os.SameFile(oldInfo, newInfo)
+}
+
 // Editable Properties
 func (n *Note) SetTitle(newTitle string) error {
 	oldTitle := n.Title
+	if titleTaken(noteFilepath(n.NotesDir, oldTitle), noteFilepath(n.NotesDir, newTitle)) {
+		return ErrTitleExists
+	}
+
 	n.Title = newTitle
+	if err := os.MkdirAll(filepath.Dir(noteFilepath(n.NotesDir, newTitle)), 0o755); err != nil {
+		return fmt.Errorf("create note directory: %w", err)
+	}
+
 	if err := os.Rename(
 		noteFilepath(n.NotesDir, oldTitle),
 		noteFilepath(n.NotesDir, newTitle),
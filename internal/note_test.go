@@ -1,9 +1,17 @@
 package internal
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestReImageBase64(t *testing.T) {
@@ -11,3 +19,104 @@ func TestReImageBase64(t *testing.T) {
 	got := _reImageBase64.ReplaceAllString(text, "")
 	assert.Equal(t, `абоба  aboba`, got)
 }
+
+func TestIsValidPath(t *testing.T) {
+	for path, valid := range map[string]bool{
+		"note":           true,
+		"folder/note":    true,
+		"a/b/c d":        true,
+		"/note":          false,
+		"folder/":        false,
+		"a//b":           false,
+		"../note":        false,
+		"a/../../note":   false,
+		"./note":         false,
+		".trash/note":    false,
+		"folder/.hidden": false,
+		`folder\note`:    false,
+		"folder/no:te":   false,
+	} {
+		assert.Equal(t, valid, isValidPath(path), path)
+	}
+}
+
+func TestEtagMatches(t *testing.T) {
+	etag := contentETag([]byte("content"))
+	assert.True(t, etagMatches(etag, etag))
+	assert.True(t, etagMatches(`"other", `+etag, etag))
+	assert.True(t, etagMatches("*", etag))
+	assert.False(t, etagMatches(`"other"`, etag))
+	assert.NotEqual(t, etag, contentETag([]byte("changed")))
+}
+
+func TestUpdateNote(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+
+	_, err := app.CreateNote(ctx, NotePostModel{Title: "note", Content: "old"})
+	require.NoError(t, err)
+
+	res, err := app.UpdateNote(ctx, "note", NotePatchModel{NewContent: lo.ToPtr("new")}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "note", res.Title)
+	assert.Equal(t, "new", *res.Content)
+
+	_, err = app.UpdateNote(ctx, "missing", NotePatchModel{NewContent: lo.ToPtr("new")}, "")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = app.UpdateNote(ctx, "note", NotePatchModel{NewTitle: lo.ToPtr("a/b")}, "")
+	assert.ErrorIs(t, err, ErrTitleInvalid)
+}
+
+func TestUpdateNoteConcurrentIfMatch(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+
+	created, err := app.CreateNote(ctx, NotePostModel{Title: "note", Content: "old"})
+	require.NoError(t, err)
+
+	// all writers base their change on the same version, only one must win
+	const writers = 8
+	var wg sync.WaitGroup
+	var updated atomic.Int32
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			_, err := app.UpdateNote(ctx, "note", NotePatchModel{NewContent: lo.ToPtr(fmt.Sprint("new", i))}, created.ETag)
+			var conflict *VersionConflictError
+			if err == nil {
+				updated.Add(1)
+			} else {
+				assert.ErrorAs(t, err, &conflict)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), updated.Load())
+}
+
+func TestSearchSkipsDeletedNotes(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+	dir := app.Dir
+
+	for _, title := range []string{"kept", "deleted"} {
+		_, err := app.CreateNote(ctx, NotePostModel{Title: title, Content: "word"})
+		require.NoError(t, err)
+	}
+
+	// note is deleted behind index back
+	require.NoError(t, os.Remove(filepath.Join(dir, "deleted.md")))
+
+	res, err := app.Search("word", SortTitle, OrderAsc, 0, "", "")
+	require.NoError(t, err)
+	// deleted note is counted until index is synced
+	assert.Equal(t, 2, res.Total)
+	assert.Equal(t, []string{"kept"}, lo.Map(res.Results, func(result SearchResultModel, _ int) string {
+		return result.Title
+	}))
+	assert.Equal(t, "deleted", <-app.resync)
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/samber/lo"
+)
+
+var ErrCursorInvalid = fmt.Errorf("The specified cursor is invalid.")
+
+// encodeCursor makes opaque cursor from position in a sorted listing.
+func encodeCursor(position any) string {
+	b, err := json.Marshal(position)
+	if err != nil {
+		panic(fmt.Sprintf("marshal cursor: %s", err.Error()))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads position in a sorted listing from cursor.
+func decodeCursor(cursor string, position any) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrCursorInvalid
+	}
+
+	if err := json.Unmarshal(b, position); err != nil {
+		return ErrCursorInvalid
+	}
+
+	return nil
+}
+
+// paginate returns at most limit items following after among items sorted by
+// compare, all following items if limit is not positive. Also reports whether
+// there are more items left.
+func paginate[T, P any](items []T, compare func(T, P) int, after *P, limit int) ([]T, bool) {
+	if after != nil {
+		i, found := slices.BinarySearchFunc(items, *after, compare)
+		if found {
+			i++
+		}
+		items = items[i:]
+	}
+
+	if limit <= 0 || len(items) <= limit {
+		return items, false
+	}
+	return items[:limit], true
+}
+
+// paginateList returns page of at most limit items following cursor, all
+// following items if limit is not positive. Items must be sorted by compare of
+// their keys, key of the last item on page is the cursor of the next page.
+func paginateList[T, K any](
+	items []T,
+	key func(T) K,
+	compare func(K, K) int,
+	limit int,
+	cursor string,
+) (ListResponseModel[T], error) {
+	var after *K
+	if cursor != "" {
+		after = new(K)
+		if err := decodeCursor(cursor, after); err != nil {
+			return ListResponseModel[T]{}, err
+		}
+	}
+
+	page, more := paginate(items, func(item T, position K) int {
+		return compare(key(item), position)
+	}, after, limit)
+
+	res := ListResponseModel[T]{
+		Items: page,
+	}
+	if more {
+		res.NextCursor = lo.ToPtr(encodeCursor(key(page[len(page)-1])))
+	}
+	return res, nil
+}
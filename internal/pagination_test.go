@@ -0,0 +1,38 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginateList(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	key := func(item string) string { return item }
+
+	got := []string{}
+	cursor := ""
+	for {
+		page, err := paginateList(items, key, strings.Compare, 2, cursor)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(page.Items), 2)
+		got = append(got, page.Items...)
+
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	assert.Equal(t, items, got)
+
+	// cursor stays valid when its item is gone
+	page, err := paginateList([]string{"a", "c", "d"}, key, strings.Compare, 0, encodeCursor("b"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, page.Items)
+	assert.Nil(t, page.NextCursor)
+
+	_, err = paginateList(items, key, strings.Compare, 2, "not a cursor")
+	assert.Equal(t, ErrCursorInvalid, err)
+}
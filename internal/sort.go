@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"cmp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rprtr258/flatnotes/internal/fts"
+)
+
+// naturalCompare compares strings case-insensitively, treating runs of digits
+// as numbers, so that "note 2" < "Note 10".
+func naturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		ra, _ := utf8.DecodeRuneInString(a)
+		rb, _ := utf8.DecodeRuneInString(b)
+		if isDigit(ra) && isDigit(rb) {
+			var na, nb string
+			na, a = splitDigits(a)
+			nb, b = splitDigits(b)
+
+			// compare numbers ignoring leading zeros, longer number is bigger
+			na, nb = strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if c := cmp.Compare(len(na), len(nb)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(na, nb); c != 0 {
+				return c
+			}
+			continue
+		}
+
+		if c := cmp.Compare(unicode.ToLower(ra), unicode.ToLower(rb)); c != 0 {
+			return c
+		}
+		a, b = a[utf8.RuneLen(ra):], b[utf8.RuneLen(rb):]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func isDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+// splitDigits splits s into leading digits and the rest.
+func splitDigits(s string) (string, string) {
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !isDigit(r)
+	})
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// compareTitles orders titles naturally, falling back to exact comparison so
+// that distinct titles never compare equal.
+func compareTitles(a, b string) int {
+	if c := naturalCompare(a, b); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// hitsComparator returns comparison function ordering search hits by given
+// sort field and order. Ties are broken by title, so order is deterministic.
+func hitsComparator(sortt Sort, order Order) func(i, j fts.Hit[NoteDocument]) int {
+	byTitle := func(i, j fts.Hit[NoteDocument]) int {
+		return compareTitles(i.Doc.Title, j.Doc.Title)
+	}
+	byLastModified := func(i, j fts.Hit[NoteDocument]) int {
+		return i.Doc.Modtime.Compare(j.Doc.Modtime)
+	}
+	byScore := func(i, j fts.Hit[NoteDocument]) int {
+		return cmp.Compare(i.Score, j.Score)
+	}
+
+	var primary func(i, j fts.Hit[NoteDocument]) int
+	switch sortt {
+	case SortTitle:
+		primary = byTitle
+	case SortLastModified:
+		primary = byLastModified
+	default:
+		// more recently modified notes go first among equally scored ones
+		primary = func(i, j fts.Hit[NoteDocument]) int {
+			if c := byScore(i, j); c != 0 {
+				return c
+			}
+			return byLastModified(i, j)
+		}
+	}
+
+	return func(i, j fts.Hit[NoteDocument]) int {
+		c := primary(i, j)
+		if order != OrderAsc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+		return byTitle(i, j)
+	}
+}
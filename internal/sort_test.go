@@ -0,0 +1,14 @@
+package internal
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	titles := []string{"note 10", "Note 2", "note 1", "apple", "Note 02b", "note", "Банан", "банан 3"}
+	slices.SortFunc(titles, compareTitles)
+	assert.Equal(t, []string{"apple", "note", "note 1", "Note 2", "Note 02b", "note 10", "Банан", "банан 3"}, titles)
+}
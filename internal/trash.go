@@ -0,0 +1,295 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// _trashDir is directory in notes directory where deleted notes are kept
+// until purged. Each deleted note is kept in its own directory named by
+// deletion time, so notes with same title can be deleted several times.
+const _trashDir = ".trash"
+
+var _reTrashID = regexp.MustCompile(`^\d+$`)
+
+func (app *App) trashDir() string {
+	return filepath.Join(app.Dir, _trashDir)
+}
+
+// trashNote moves note file into trash.
+func (app *App) trashNote(note Note) error {
+	if err := os.MkdirAll(app.trashDir(), 0o755); err != nil {
+		return fmt.Errorf("create trash directory: %w", err)
+	}
+
+	for id := time.Now().UnixNano(); ; id++ {
+		dir := filepath.Join(app.trashDir(), strconv.FormatInt(id, 10))
+		if err := os.Mkdir(dir, 0o755); errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("create trash directory: %w", err)
+		}
+
+		trashPath := noteFilepath(dir, note.Title)
+		if err := os.MkdirAll(filepath.Dir(trashPath), 0o755); err != nil {
+			return fmt.Errorf("create trash directory: %w", err)
+		}
+
+		return os.Rename(noteFilepath(note.NotesDir, note.Title), trashPath)
+	}
+}
+
+// linkFile makes file at src available at dst, failing with fs.ErrExist if
+// dst exists. Unlike rename, hard link does not replace existing file, on
+// filesystems without hard links file is copied instead.
+func linkFile(src, dst string) error {
+	err := os.Link(src, dst)
+	if errors.Is(err, errors.ErrUnsupported) || errors.Is(err, fs.ErrPermission) {
+		return copyFile(src, dst)
+	}
+	return err
+}
+
+// copyFile copies file at src to dst, failing with fs.ErrExist if dst exists.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// trashedNote returns trashed note with given id.
+func (app *App) trashedNote(id string) (TrashedNoteModel, error) {
+	if !_reTrashID.MatchString(id) {
+		return TrashedNoteModel{}, ErrNotFound
+	}
+
+	nanos, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return TrashedNoteModel{}, ErrNotFound
+	}
+
+	dir := filepath.Join(app.trashDir(), id)
+	res := TrashedNoteModel{
+		ID:        id,
+		DeletedAt: time.Unix(0, nanos).Unix(),
+	}
+	found := false
+	if err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.HasSuffix(path, _markdownExt) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		res.Title = filepath.ToSlash(strings.TrimSuffix(rel, _markdownExt))
+		res.Size = info.Size()
+		found = true
+		return filepath.SkipAll
+	}); errors.Is(err, fs.ErrNotExist) {
+		return TrashedNoteModel{}, ErrNotFound
+	} else if err != nil {
+		return TrashedNoteModel{}, fmt.Errorf("walk %q: %w", dir, err)
+	}
+
+	if !found {
+		return TrashedNoteModel{}, ErrNotFound
+	}
+	return res, nil
+}
+
+// GetTrash returns page of at most limit trashed notes following cursor, most
+// recently deleted first.
+func (app *App) GetTrash(limit int, cursor string) (ListResponseModel[TrashedNoteModel], error) {
+	notes, err := app.trashedNotes()
+	if err != nil {
+		return ListResponseModel[TrashedNoteModel]{}, err
+	}
+
+	return paginateList(notes, func(note TrashedNoteModel) string {
+		return note.ID
+	}, compareTrashIDs, limit, cursor)
+}
+
+// compareTrashIDs orders trashed notes, most recently deleted first.
+func compareTrashIDs(a, b string) int {
+	return strings.Compare(b, a)
+}
+
+// trashedNotes returns list of trashed notes, most recently deleted first.
+func (app *App) trashedNotes() ([]TrashedNoteModel, error) {
+	entries, err := os.ReadDir(app.trashDir())
+	if errors.Is(err, fs.ErrNotExist) {
+		return []TrashedNoteModel{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read trash directory: %w", err)
+	}
+
+	res := []TrashedNoteModel{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		note, err := app.trashedNote(entry.Name())
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		res = append(res, note)
+	}
+
+	slices.SortFunc(res, func(a, b TrashedNoteModel) int {
+		return compareTrashIDs(a.ID, b.ID)
+	})
+	return res, nil
+}
+
+// RestoreTrashedNote moves note from trash back to notes directory, under
+// newTitle if it is not empty. ErrTitleExists is returned if title is taken.
+func (app *App) RestoreTrashedNote(ctx context.Context, id, newTitle string) (NoteContentResponseModel, error) {
+	trashed, err := app.trashedNote(id)
+	if err != nil {
+		return NoteContentResponseModel{}, err
+	}
+
+	title := trashed.Title
+	if newTitle != "" {
+		title = newTitle
+	}
+
+	if !app.validTitle(title) {
+		return NoteContentResponseModel{}, ErrTitleInvalid
+	}
+
+	app.writeMu.Lock()
+	defer app.writeMu.Unlock()
+
+	trashDir := filepath.Join(app.trashDir(), id)
+	notePath := noteFilepath(app.Dir, title)
+	if err := os.MkdirAll(filepath.Dir(notePath), 0o755); err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("create note directory: %w", err)
+	}
+
+	if err := linkFile(noteFilepath(trashDir, trashed.Title), notePath); errors.Is(err, fs.ErrExist) {
+		return NoteContentResponseModel{}, ErrTitleExists
+	} else if err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("restore note %q: %w", title, err)
+	}
+
+	if err := os.RemoveAll(trashDir); err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("remove trashed note %s: %w", id, err)
+	}
+
+	if err := app.syncNote(title); err != nil {
+		return NoteContentResponseModel{}, fmt.Errorf("index note %q: %w", title, err)
+	}
+
+	restored, err := app.GetNote(title, true)
+	if err != nil {
+		return NoteContentResponseModel{}, err
+	}
+
+	app.saveVersion(title, nil, []byte(*restored.Content), HistoryActionRestore)
+	app.commit(ctx, "Restore "+title+" from trash", title)
+	return restored, nil
+}
+
+// PurgeTrashedNote permanently deletes note from trash.
+func (app *App) PurgeTrashedNote(id string) error {
+	if _, err := app.trashedNote(id); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(filepath.Join(app.trashDir(), id))
+}
+
+// PurgeTrash permanently deletes notes trashed before given time.
+func (app *App) PurgeTrash(before time.Time) error {
+	notes, err := app.trashedNotes()
+	if err != nil {
+		return err
+	}
+
+	for _, note := range notes {
+		if nanos, _ := strconv.ParseInt(note.ID, 10, 64); !time.Unix(0, nanos).Before(before) {
+			continue
+		}
+
+		if err := app.PurgeTrashedNote(note.ID); err != nil {
+			return fmt.Errorf("purge note %s: %w", note.ID, err)
+		}
+
+		log.Printf("%q purged from trash\n", note.Title)
+	}
+	return nil
+}
+
+// _trashPurgeInterval is how often trash is checked for expired notes.
+const _trashPurgeInterval = time.Hour
+
+// CleanTrash permanently deletes notes trashed more than retention ago until
+// ctx is done. Trash is never cleaned if retention is not positive.
+func (app *App) CleanTrash(ctx context.Context, retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(_trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := app.PurgeTrash(time.Now().Add(-retention)); err != nil {
+			log.Println("purge trash:", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrash(t *testing.T) {
+	ctx := context.Background()
+	app := newTestApp(t)
+
+	_, err := app.CreateNote(ctx, NotePostModel{Title: "note", Content: "deleted"})
+	require.NoError(t, err)
+	require.NoError(t, app.DeleteNote(ctx, "note", ""))
+	_, err = app.CreateNote(ctx, NotePostModel{Title: "note", Content: "new"})
+	require.NoError(t, err)
+
+	trash, err := app.GetTrash(0, "")
+	require.NoError(t, err)
+	require.Len(t, trash.Items, 1)
+	assert.Equal(t, "note", trash.Items[0].Title)
+
+	_, err = app.RestoreTrashedNote(ctx, trash.Items[0].ID, "")
+	assert.Equal(t, ErrTitleExists, err)
+
+	restored, err := app.RestoreTrashedNote(ctx, trash.Items[0].ID, "restored")
+	require.NoError(t, err)
+	assert.Equal(t, "deleted", *restored.Content)
+
+	require.NoError(t, app.DeleteNote(ctx, "restored", ""))
+	require.NoError(t, app.PurgeTrash(time.Now()))
+	trash, err = app.GetTrash(0, "")
+	require.NoError(t, err)
+	assert.Empty(t, trash.Items)
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.md"), filepath.Join(dir, "dst.md")
+	require.NoError(t, os.WriteFile(src, []byte("content"), 0o644))
+
+	require.NoError(t, copyFile(src, dst))
+	content, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+
+	// existing file is not replaced
+	require.NoError(t, os.WriteFile(src, []byte("other"), 0o644))
+	assert.ErrorIs(t, copyFile(src, dst), fs.ErrExist)
+	content, err = os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "content", string(content))
+}
@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/samber/lo"
+
+	"github.com/rprtr258/flatnotes/internal/fts"
+)
+
+// _warmupMaxWorkers is maximum number of notes read and tokenized at once
+// during initial indexing.
+const _warmupMaxWorkers = 8
+
+// warmup is progress of initial indexing, done in background so the server
+// is available meanwhile.
+type warmup struct {
+	start time.Time
+	// notes to index and notes indexed so far
+	total, done atomic.Int64
+	cancel      context.CancelFunc
+	// closed once initial indexing is finished
+	finished chan struct{}
+	// why initial indexing failed, set before finished is closed
+	err error
+	// closed once index is saved after initial indexing
+	stopped chan struct{}
+}
+
+// isComplete reports whether initial indexing finished successfully.
+func (w *warmup) isComplete() bool {
+	select {
+	case <-w.finished:
+		return w.err == nil
+	default:
+		return false
+	}
+}
+
+// startWarmup loads saved index and starts initial indexing of notes changed
+// since it was saved.
+func (app *App) startWarmup() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &warmup{
+		start:    time.Now(),
+		cancel:   cancel,
+		finished: make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	app.warmup = w
+
+	go func() {
+		defer close(w.stopped)
+
+		log.Println("started initial indexing")
+		if err := app.warmUp(ctx); err != nil {
+			w.err = fmt.Errorf("initial indexing: %w", err)
+		}
+		close(w.finished)
+		if w.err != nil {
+			log.Println(w.err.Error())
+			return
+		}
+		log.Printf("finished initial indexing of %d notes in %s\n", w.total.Load(), time.Since(w.start))
+
+		if err := app.saveIndex(); err != nil {
+			log.Println("save index:", err.Error())
+		}
+	}()
+}
+
+// warmUp loads saved index and synchronizes it with notes directory. Notes are
+// read and tokenized by pool of workers. Changes found are not published, as
+// nobody could have seen previous state.
+func (app *App) warmUp(ctx context.Context) error {
+	// writers wait for saved index, so it does not overwrite their changes
+	app.indexMu.Lock()
+	if err := app.loadIndex(); err != nil {
+		log.Println("rebuilding index from scratch:", err.Error())
+	}
+
+	for title, doc := range app.Index.Documents() {
+		app.links.Set(title, doc.Links)
+	}
+	app.indexMu.Unlock()
+
+	notes, err := app.getNotes()
+	if err != nil {
+		return fmt.Errorf("get notes: %w", err)
+	}
+
+	indexed := app.Index.Documents()
+	found := Set[string]{}
+	todo := []Note{}
+	for _, note := range notes {
+		found[note.Title] = struct{}{}
+
+		if doc, ok := indexed[note.Title]; ok && app.upToDate(doc) {
+			continue
+		}
+
+		todo = append(todo, note)
+	}
+
+	app.indexMu.Lock()
+	for title := range indexed {
+		if found.Has(title) {
+			continue
+		}
+
+		app.Index.Remove(title)
+		app.links.Remove(title)
+		log.Println(title, "removed from index")
+	}
+	app.indexMu.Unlock()
+
+	app.warmup.total.Store(int64(len(todo)))
+
+	jobs := make(chan Note)
+	go func() {
+		defer close(jobs)
+
+		for _, note := range todo {
+			select {
+			case <-ctx.Done():
+				return
+			case jobs <- note:
+			}
+		}
+	}()
+
+	docs := make(chan fts.Analyzed[NoteDocument])
+	var wg sync.WaitGroup
+	for i := 0; i < min(runtime.GOMAXPROCS(0), _warmupMaxWorkers); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for note := range jobs {
+				doc, err := toDocument(note)
+				if err == nil {
+					docs <- fts.Analyze(doc)
+					continue
+				}
+
+				if errors.Is(err, fs.ErrNotExist) {
+					// removed meanwhile
+					err = app.syncNote(note.Title)
+				}
+				if err != nil {
+					log.Printf("index %q: %s\n", note.Title, err.Error())
+				}
+				app.warmup.done.Add(1)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(docs)
+	}()
+
+	for doc := range docs {
+		if err := app.warmupAdd(doc); err != nil {
+			log.Printf("index %q: %s\n", doc.Doc.Title, err.Error())
+		}
+		app.warmup.done.Add(1)
+	}
+
+	return ctx.Err()
+}
+
+// upToDate reports whether indexed document matches note file.
+func (app *App) upToDate(doc NoteDocument) bool {
+	stat, err := os.Stat(noteFilepath(app.Dir, doc.Title))
+	return err == nil && stat.ModTime().Equal(doc.Modtime) && stat.Size() == doc.Size
+}
+
+// warmupAdd adds document read during initial indexing to index. Notes changed
+// since they were read are synced as any other change.
+func (app *App) warmupAdd(doc fts.Analyzed[NoteDocument]) error {
+	app.indexMu.Lock()
+	if !app.upToDate(doc.Doc) {
+		app.indexMu.Unlock()
+		return app.syncNote(doc.Doc.Title)
+	}
+
+	app.Index.UpsertAnalyzed(doc)
+	app.links.Set(doc.Doc.Title, doc.Doc.Links)
+	app.indexMu.Unlock()
+	return nil
+}
+
+// Indexed reports whether initial indexing is finished, so index has all
+// notes.
+func (app *App) Indexed() bool {
+	return app.warmup == nil || app.warmup.isComplete()
+}
+
+// WaitIndexed waits until initial indexing is finished or ctx is done. Error
+// is returned if initial indexing failed.
+func (app *App) WaitIndexed(ctx context.Context) error {
+	if app.warmup == nil {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-app.warmup.finished:
+		return app.warmup.err
+	}
+}
+
+// stopWarmup cancels initial indexing and waits for it to stop.
+func (app *App) stopWarmup() {
+	if app.warmup == nil {
+		return
+	}
+
+	app.warmup.cancel()
+	<-app.warmup.stopped
+}
+
+// GetStatus returns progress of initial indexing.
+func (app *App) GetStatus() StatusModel {
+	res := StatusModel{
+		Notes: app.Index.Len(),
+	}
+	if app.warmup == nil {
+		return res
+	}
+
+	done, total := app.warmup.done.Load(), app.warmup.total.Load()
+	res.Done = int(done)
+	res.Total = int(total)
+	select {
+	case <-app.warmup.finished:
+		res.Error = errorString(app.warmup.err)
+	default:
+		elapsed := time.Since(app.warmup.start).Seconds()
+		res.Indexing = true
+		res.Elapsed = elapsed
+		if done > 0 {
+			res.ETA = lo.ToPtr(elapsed / float64(done) * float64(total-done))
+		}
+	}
+	return res
+}
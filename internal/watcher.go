@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// _watchDebounce is time to wait for more changes before updating index, so
+// bursts of writes, e.g. from editors saving through temporary files, result
+// in single update.
+const _watchDebounce = 200 * time.Millisecond
+
+// Watch keeps index in sync with notes directory until ctx is done. Changed
+// notes are reindexed as filesystem notifications arrive, additionally whole
+// directory is reconciled every reconcileInterval in case some notifications
+// were missed. If notifications are not available, only periodic reconcile is
+// done.
+func (app *App) Watch(ctx context.Context, reconcileInterval time.Duration) error {
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Println("filesystem notifications are not available, falling back to periodic reconcile:", err.Error())
+	} else {
+		defer watcher.Close()
+
+		if err := app.watchDir(watcher, app.Dir); err != nil {
+			return fmt.Errorf("watch %q: %w", app.Dir, err)
+		}
+
+		events, errs = watcher.Events, watcher.Errors
+	}
+
+	// changes made during initial indexing are found by it, notifications
+	// arriving meanwhile are queued
+	if app.WaitIndexed(ctx) != nil && ctx.Err() != nil {
+		return nil
+	}
+
+	var reconcile <-chan time.Time
+	if reconcileInterval > 0 {
+		ticker := time.NewTicker(reconcileInterval)
+		defer ticker.Stop()
+		reconcile = ticker.C
+	}
+
+	debounce := time.NewTimer(_watchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	pending := Set[string]{}
+	// whether directory was changed, so all notes must be reconciled
+	pendingReconcile := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-events:
+			if title, ok := app.titleFromPath(event.Name); ok {
+				pending[title] = struct{}{}
+			} else if app.Recursive && !event.Has(fsnotify.Write) && !event.Has(fsnotify.Chmod) {
+				// directory might be created, moved or removed, with no events
+				// for notes inside
+				if stat, err := os.Stat(event.Name); err == nil && stat.IsDir() && !skipDir(stat.Name()) {
+					if err := app.watchDir(watcher, event.Name); err != nil {
+						log.Printf("watch %q: %s\n", event.Name, err.Error())
+					}
+				}
+				pendingReconcile = true
+			} else {
+				continue
+			}
+
+			debounce.Reset(_watchDebounce)
+		case title := <-app.resync:
+			pending[title] = struct{}{}
+			debounce.Reset(_watchDebounce)
+		case <-debounce.C:
+			if pendingReconcile {
+				if err := app.updateIndex(); err != nil {
+					log.Println("update index:", err.Error())
+				}
+			} else {
+				for title := range pending {
+					if err := app.syncNote(title); err != nil {
+						log.Printf("sync note %q: %s\n", title, err.Error())
+					}
+				}
+			}
+			clear(pending)
+			pendingReconcile = false
+		case err := <-errs:
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				log.Println("watch notes directory:", err.Error())
+			}
+
+			// some changes might be lost
+			if err := app.updateIndex(); err != nil {
+				log.Println("update index:", err.Error())
+			}
+		case <-reconcile:
+			if err := app.updateIndex(); err != nil {
+				log.Println("update index:", err.Error())
+			}
+		}
+	}
+}
+
+// watchDir adds watches for directory and, in recursive mode, all its notes
+// subdirectories.
+func (app *App) watchDir(watcher *fsnotify.Watcher, dir string) error {
+	if !app.Recursive {
+		return watcher.Add(dir)
+	}
+
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() {
+			return nil
+		}
+
+		if path != app.Dir && skipDir(d.Name()) {
+			return filepath.SkipDir
+		}
+
+		return watcher.Add(path)
+	})
+}
+
+// titleFromPath returns title of note stored at path, if path is a note file.
+func (app *App) titleFromPath(path string) (string, bool) {
+	if !strings.HasSuffix(path, _markdownExt) {
+		return "", false
+	}
+
+	rel, err := filepath.Rel(app.Dir, path)
+	if err != nil {
+		return "", false
+	}
+
+	title := filepath.ToSlash(strings.TrimSuffix(rel, _markdownExt))
+	if dir, _, ok := strings.Cut(title, "/"); ok && skipDir(dir) {
+		return "", false
+	}
+
+	return title, app.validTitle(title)
+}
+
+// _resyncQueueSize is number of notes which might wait for sync, more are
+// left for periodic reconcile.
+const _resyncQueueSize = 100
+
+// scheduleSync schedules sync of note index entry, it is done by Watch.
+func (app *App) scheduleSync(title string) {
+	select {
+	case app.resync <- title:
+	default:
+	}
+}
+
+// coalescer runs function one call at a time. Calls made while function runs
+// are coalesced into single next run, which all of them wait for. Zero value
+// is ready to use.
+type coalescer struct {
+	mu      sync.Mutex
+	running bool
+	// next run, nil if it is not requested
+	next *coalescedRun
+}
+
+type coalescedRun struct {
+	done chan struct{}
+	err  error
+}
+
+// Do runs f, or waits for next run of function if it is running already.
+func (c *coalescer) Do(f func() error) error {
+	c.mu.Lock()
+	if c.running {
+		if c.next == nil {
+			c.next = &coalescedRun{done: make(chan struct{})}
+		}
+		run := c.next
+		c.mu.Unlock()
+
+		<-run.done
+		return run.err
+	}
+	c.running = true
+	c.mu.Unlock()
+
+	err := f()
+	go c.runNext(f)
+	return err
+}
+
+// runNext runs requested runs until there are no more.
+func (c *coalescer) runNext(f func() error) {
+	for {
+		c.mu.Lock()
+		run := c.next
+		c.next = nil
+		if run == nil {
+			c.running = false
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+
+		run.err = f()
+		close(run.done)
+	}
+}
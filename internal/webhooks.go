@@ -0,0 +1,382 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// _webhookMaxAttempts is number of attempts to deliver event to webhook.
+	_webhookMaxAttempts = 5
+	// _webhookRetryDelay is delay before first retry, it doubles with each
+	// next retry.
+	_webhookRetryDelay = 5 * time.Second
+	// _webhookTimeout is time webhook is given to respond.
+	_webhookTimeout = 10 * time.Second
+	// _webhookQueueSize is number of events waiting for delivery to webhook,
+	// more events are dropped.
+	_webhookQueueSize = 1000
+	// _webhookLogMaxSize is size of delivery log after which it is rotated.
+	_webhookLogMaxSize = 1 << 20
+
+	// _webhookSignatureHeader is header with HMAC-SHA256 of request body
+	// keyed with webhook secret, as "sha256=<hex>".
+	_webhookSignatureHeader = "X-Flatnotes-Signature"
+	_webhookEventHeader     = "X-Flatnotes-Event"
+	_webhookDeliveryHeader  = "X-Flatnotes-Delivery"
+)
+
+// Webhook receives note changes as JSON events in POST requests. Webhooks are
+// configured in YAML file as list of them, e.g.:
+//
+//   - url: https://example.com/hook
+//     secret: secret-to-sign-requests-with
+//     events: [created, updated]
+//     tags: [publish]
+//     titles: ["blog/*"]
+//
+// Filters are optional, empty filter matches all changes.
+type Webhook struct {
+	URL    string `yaml:"url"`
+	Secret string `yaml:"secret"`
+	// Types of changes to deliver
+	Events []EventType `yaml:"events"`
+	// Changes are delivered if note has any of tags
+	Tags []string `yaml:"tags"`
+	// Changes are delivered if note title matches any of patterns, in
+	// path.Match syntax
+	Titles []string `yaml:"titles"`
+}
+
+// matches reports whether event passes webhook filters.
+func (h Webhook) matches(event Event) bool {
+	if event.Type == EventReset {
+		return false
+	}
+
+	if len(h.Events) > 0 && !slices.Contains(h.Events, event.Type) {
+		return false
+	}
+
+	if len(h.Tags) > 0 && !slices.ContainsFunc(event.Tags, func(tag string) bool {
+		return slices.Contains(h.Tags, tag)
+	}) {
+		return false
+	}
+
+	if len(h.Titles) > 0 && !slices.ContainsFunc(h.Titles, func(pattern string) bool {
+		for _, title := range []string{event.Title, event.OldTitle} {
+			if title == "" {
+				continue
+			}
+
+			if ok, _ := path.Match(pattern, title); ok {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+
+	return true
+}
+
+// loadWebhooks reads webhooks from YAML file.
+func loadWebhooks(filename string) ([]Webhook, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []Webhook
+	if err := yaml.Unmarshal(content, &res); err != nil {
+		return nil, fmt.Errorf("parse %q: %w", filename, err)
+	}
+
+	for i, hook := range res {
+		if hook.URL == "" {
+			return nil, fmt.Errorf("webhook #%d has no url", i+1)
+		}
+
+		for _, pattern := range hook.Titles {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("webhook %q title pattern %q: %w", hook.URL, pattern, err)
+			}
+		}
+	}
+	return res, nil
+}
+
+// signWebhook returns signature of webhook request body.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Webhooks delivers note changes to webhooks, retrying failed deliveries.
+// Every delivery attempt is written to log.
+type Webhooks struct {
+	hooks   []Webhook
+	client  *http.Client
+	logPath string
+	// delay before first retry
+	retryDelay  time.Duration
+	maxAttempts int
+
+	// guards delivery log
+	mu sync.Mutex
+}
+
+func newWebhooks(hooks []Webhook, logPath string) *Webhooks {
+	return &Webhooks{
+		hooks:       hooks,
+		client:      &http.Client{Timeout: _webhookTimeout},
+		logPath:     logPath,
+		retryDelay:  _webhookRetryDelay,
+		maxAttempts: _webhookMaxAttempts,
+	}
+}
+
+// Run delivers events to webhooks until ctx is done. Each webhook gets events
+// in order they happened.
+func (w *Webhooks) Run(ctx context.Context, events *Events) {
+	queues := make([]chan Event, len(w.hooks))
+	for i, hook := range w.hooks {
+		queues[i] = make(chan Event, _webhookQueueSize)
+		go w.deliverAll(ctx, hook, queues[i])
+	}
+
+	dispatch := func(event Event) {
+		for i, hook := range w.hooks {
+			if !hook.matches(event) {
+				continue
+			}
+
+			select {
+			case queues[i] <- event:
+			default:
+				log.Printf("webhook %q queue is full, event %d dropped\n", hook.URL, event.ID)
+			}
+		}
+	}
+
+	lastEventID := ""
+	for {
+		// events are resubscribed to from last one if webhooks fall behind
+		missed, ch, unsubscribe := events.Subscribe(lastEventID)
+		for _, event := range missed {
+			dispatch(event)
+			lastEventID = strconv.FormatUint(event.ID, 10)
+		}
+
+	loop:
+		for {
+			select {
+			case <-ctx.Done():
+				unsubscribe()
+				return
+			case event, ok := <-ch:
+				if !ok {
+					break loop
+				}
+
+				dispatch(event)
+				lastEventID = strconv.FormatUint(event.ID, 10)
+			}
+		}
+		unsubscribe()
+
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// deliverAll delivers events from queue to webhook until ctx is done.
+func (w *Webhooks) deliverAll(ctx context.Context, hook Webhook, queue <-chan Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-queue:
+			w.deliver(ctx, hook, event)
+		}
+	}
+}
+
+// deliver sends event to webhook, retrying with exponential backoff until it
+// succeeds or attempts are exhausted.
+func (w *Webhooks) deliver(ctx context.Context, hook Webhook, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("marshal event %d: %s\n", event.ID, err.Error())
+		return
+	}
+
+	delay := w.retryDelay
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		status, err := w.send(ctx, hook, event, body)
+		w.logDelivery(WebhookDeliveryModel{
+			Timestamp: start.Unix(),
+			URL:       hook.URL,
+			EventID:   event.ID,
+			Event:     event.Type,
+			Title:     event.Title,
+			Attempt:   attempt,
+			Status:    status,
+			Error:     errorString(err),
+			Duration:  time.Since(start).Milliseconds(),
+		})
+		if err == nil || attempt == w.maxAttempts {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// send sends event to webhook, returning response status.
+func (w *Webhooks) send(ctx context.Context, hook Webhook, event Event, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(_webhookEventHeader, string(event.Type))
+	req.Header.Set(_webhookDeliveryHeader, strconv.FormatUint(event.ID, 10))
+	if hook.Secret != "" {
+		req.Header.Set(_webhookSignatureHeader, signWebhook(hook.Secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// body is drained, so connection is reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// logDelivery appends delivery attempt to log as JSON line. Log is rotated
+// once it grows too large, so only previous log is kept.
+func (w *Webhooks) logDelivery(delivery WebhookDeliveryModel) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if delivery.Error != "" {
+		log.Printf("deliver event %d to webhook %q, attempt %d: %s\n", delivery.EventID, delivery.URL, delivery.Attempt, delivery.Error)
+	}
+
+	if err := w.appendLog(delivery); err != nil {
+		log.Println("write webhook delivery log:", err.Error())
+	}
+}
+
+func (w *Webhooks) appendLog(delivery WebhookDeliveryModel) error {
+	if info, err := os.Stat(w.logPath); err == nil && info.Size() > _webhookLogMaxSize {
+		if err := os.Rename(w.logPath, w.logPath+".1"); err != nil {
+			return fmt.Errorf("rotate: %w", err)
+		}
+	}
+
+	line, err := json.Marshal(delivery)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Deliveries returns last delivery attempts from log, most recent first.
+func (w *Webhooks) Deliveries(limit int) ([]WebhookDeliveryModel, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	res := []WebhookDeliveryModel{}
+	f, err := os.Open(w.logPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return res, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("open delivery log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var delivery WebhookDeliveryModel
+		if err := json.Unmarshal(scanner.Bytes(), &delivery); err != nil {
+			continue
+		}
+
+		res = append(res, delivery)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read delivery log: %w", err)
+	}
+
+	slices.Reverse(res)
+	if limit > 0 && len(res) > limit {
+		res = res[:limit]
+	}
+	return res, nil
+}
+
+// GetWebhookDeliveries returns last webhook delivery attempts, most recent
+// first.
+func (app *App) GetWebhookDeliveries(limit int) ([]WebhookDeliveryModel, error) {
+	if app.Webhooks == nil {
+		return []WebhookDeliveryModel{}, nil
+	}
+
+	return app.Webhooks.Deliveries(limit)
+}
@@ -0,0 +1,96 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookMatches(t *testing.T) {
+	hook := Webhook{
+		Events: []EventType{EventCreated, EventRenamed},
+		Tags:   []string{"publish"},
+		Titles: []string{"blog/*"},
+	}
+
+	assert.True(t, hook.matches(Event{Type: EventCreated, Title: "blog/post", Tags: []string{"draft", "publish"}}))
+	assert.True(t, hook.matches(Event{Type: EventRenamed, Title: "post", OldTitle: "blog/post", Tags: []string{"publish"}}))
+	assert.False(t, hook.matches(Event{Type: EventUpdated, Title: "blog/post", Tags: []string{"publish"}}))
+	assert.False(t, hook.matches(Event{Type: EventCreated, Title: "blog/post"}))
+	assert.False(t, hook.matches(Event{Type: EventCreated, Title: "blog/2024/post", Tags: []string{"publish"}}))
+	assert.False(t, Webhook{}.matches(Event{Type: EventReset}))
+}
+
+func TestWebhooksDeliver(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		bodies   [][]byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, r)
+		bodies = append(bodies, body)
+		// first attempt fails
+		if len(requests) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	webhooks := newWebhooks([]Webhook{{URL: server.URL, Secret: "secret"}}, filepath.Join(t.TempDir(), "webhooks.log"))
+	webhooks.retryDelay = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := newEvents()
+	go webhooks.Run(ctx, events)
+	require.Eventually(t, func() bool {
+		events.mu.Lock()
+		defer events.mu.Unlock()
+		return len(events.subscribers) > 0
+	}, time.Second, time.Millisecond)
+
+	events.Publish(Event{Type: EventCreated, Title: "note", Tags: []string{"tag"}})
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(requests) == 2
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, signWebhook("secret", bodies[1]), requests[1].Header.Get(_webhookSignatureHeader))
+	assert.Equal(t, string(EventCreated), requests[1].Header.Get(_webhookEventHeader))
+
+	var event Event
+	require.NoError(t, json.Unmarshal(bodies[1], &event))
+	assert.Equal(t, "note", event.Title)
+	assert.Equal(t, []string{"tag"}, event.Tags)
+
+	require.Eventually(t, func() bool {
+		deliveries, err := webhooks.Deliveries(0)
+		return err == nil && len(deliveries) == 2
+	}, time.Second, time.Millisecond)
+	deliveries, err := webhooks.Deliveries(0)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempt)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[1].Status)
+	assert.NotEmpty(t, deliveries[1].Error)
+}